@@ -0,0 +1,183 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policies for a full AsyncHandler queue.
+const (
+	// AsyncBlock makes Write wait for room in the queue.
+	AsyncBlock = iota
+	// AsyncDrop makes Write discard the message and count it in Dropped.
+	AsyncDrop
+)
+
+// ErrHandlerClosed is returned by writes to a closed handler.
+var ErrHandlerClosed = errors.New("log: handler closed")
+
+// AsyncHandler queues writes and performs them, including rotation checks,
+// on a single background goroutine, so callers never wait on disk I/O.
+// Messages are written in the order they were queued.
+type AsyncHandler struct {
+	inner  *RotateHandler
+	Policy int
+
+	mu     sync.RWMutex // guards closed against sends on a closed queue
+	closed bool
+	// closing is closed when Close starts, releasing blocked senders so they
+	// don't hold mu while the queue is full
+	closing   chan struct{}
+	closeOnce sync.Once
+	queue     chan asyncMsg
+	done      chan struct{}
+	dropped   uint64
+	// aborted is set when CloseTimeout gives up, the rest is discarded
+	aborted int32
+}
+
+type asyncMsg struct {
+	data []byte
+	// flushed, when set, marks a Flush request to acknowledge
+	flushed chan struct{}
+}
+
+// NewAsyncHandler returns a handler writing to inner through a queue holding
+// up to queueSize messages, blocking when it is full.
+func NewAsyncHandler(inner *RotateHandler, queueSize int) *AsyncHandler {
+	h := &AsyncHandler{
+		inner:   inner,
+		Policy:  AsyncBlock,
+		closing: make(chan struct{}),
+		queue:   make(chan asyncMsg, queueSize),
+		done:    make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+func (h *AsyncHandler) run() {
+	defer close(h.done)
+	for msg := range h.queue {
+		aborted := atomic.LoadInt32(&h.aborted) == 1
+		if msg.flushed != nil {
+			if !aborted {
+				h.inner.Flush()
+			}
+			close(msg.flushed)
+			continue
+		}
+		if !aborted {
+			h.inner.Write(msg.data)
+		}
+	}
+}
+
+func (h *AsyncHandler) Init() {
+	h.inner.Init()
+}
+
+func (h *AsyncHandler) InitE() error {
+	return h.inner.InitE()
+}
+
+// Write queues a copy of data, returning before it reaches the file.
+func (h *AsyncHandler) Write(data []byte) (int, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		return 0, ErrHandlerClosed
+	}
+	msg := asyncMsg{data: append([]byte(nil), data...)}
+	if h.Policy == AsyncDrop {
+		select {
+		case h.queue <- msg:
+		default:
+			atomic.AddUint64(&h.dropped, 1)
+		}
+		return len(data), nil
+	}
+	select {
+	case h.queue <- msg:
+		return len(data), nil
+	case <-h.closing:
+		return 0, ErrHandlerClosed
+	}
+}
+
+// Dropped returns the number of messages discarded under AsyncDrop, or by
+// CloseTimeout.
+func (h *AsyncHandler) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// Flush waits until every message queued so far is written, then flushes
+// the underlying handler.
+func (h *AsyncHandler) Flush() {
+	h.mu.RLock()
+	if h.closed {
+		h.mu.RUnlock()
+		return
+	}
+	flushed := make(chan struct{})
+	select {
+	case h.queue <- asyncMsg{flushed: flushed}:
+	case <-h.closing:
+		h.mu.RUnlock()
+		return
+	}
+	h.mu.RUnlock()
+	<-flushed
+}
+
+// Close writes every pending message, then closes the underlying handler.
+func (h *AsyncHandler) Close() {
+	h.CloseTimeout(0)
+}
+
+// activeFile reports the wrapped handler's file, without the lines still
+// queued.
+func (h *AsyncHandler) activeFile() (string, int) {
+	return h.inner.activeFile()
+}
+
+// CloseTimeout is like Close but waits at most d, if d > 0, for the pending
+// messages to be written. If they are not, the ones still queued are
+// discarded and an error reports how many; the underlying handler is closed
+// once the write in progress returns. Writes blocked on a full queue fail
+// with ErrHandlerClosed.
+func (h *AsyncHandler) CloseTimeout(d time.Duration) error {
+	var timeout <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	h.closeOnce.Do(func() { close(h.closing) })
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	close(h.queue)
+	h.mu.Unlock()
+
+	select {
+	case <-h.done:
+		h.inner.Close()
+		return nil
+	case <-timeout:
+	}
+	atomic.StoreInt32(&h.aborted, 1)
+	pending := len(h.queue)
+	atomic.AddUint64(&h.dropped, uint64(pending))
+	go func() {
+		<-h.done
+		h.inner.Close()
+	}()
+	return fmt.Errorf("async: close timed out after %s, %d messages dropped", d, pending)
+}
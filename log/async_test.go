@@ -0,0 +1,158 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// wedge makes writes to h block until the returned func is called.
+func wedge(h *RotateHandler) (release func()) {
+	blocked := make(chan struct{})
+	h.Transforms = append(h.Transforms, func(b []byte) []byte {
+		<-blocked
+		return b
+	})
+	var once sync.Once
+	return func() { once.Do(func() { close(blocked) }) }
+}
+
+func TestAsyncWritesInOrder(t *testing.T) {
+	inner := newTestHandler(t, nil)
+	h := NewAsyncHandler(inner, 4)
+	for _, s := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := h.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Close()
+	if got := readFile(t, inner.FilePath); got != "a\nb\nc\n" {
+		t.Errorf("file = %q", got)
+	}
+	if _, err := h.Write([]byte("d\n")); err != ErrHandlerClosed {
+		t.Errorf("Write after Close = %v, want ErrHandlerClosed", err)
+	}
+}
+
+func TestAsyncCloseDrainsQueue(t *testing.T) {
+	inner := newTestHandler(t, nil)
+	release := wedge(inner)
+	h := NewAsyncHandler(inner, 1000)
+	var want strings.Builder
+	for i := 0; i < 500; i++ {
+		line := fmt.Sprintf("line %d\n", i)
+		want.WriteString(line)
+		h.Write([]byte(line))
+	}
+	// everything is still queued behind the wedged write
+	release()
+	h.Close()
+	if got := readFile(t, inner.FilePath); got != want.String() {
+		t.Errorf("file has %d lines, want the 500 queued in order", countLinesIn(got))
+	}
+}
+
+func TestAsyncDrop(t *testing.T) {
+	inner := newTestHandler(t, nil)
+	release := wedge(inner)
+	h := NewAsyncHandler(inner, 1)
+	h.Policy = AsyncDrop
+	for i := 0; i < 10; i++ {
+		h.Write([]byte("x\n"))
+	}
+	if h.Dropped() == 0 {
+		t.Error("nothing dropped with a full queue")
+	}
+	release()
+	h.Close()
+}
+
+func TestAsyncCloseTimeout(t *testing.T) {
+	inner := newTestHandler(t, nil)
+	release := wedge(inner)
+	h := NewAsyncHandler(inner, 8)
+	defer func() {
+		release()
+		<-h.done
+	}()
+	for i := 0; i < 5; i++ {
+		h.Write([]byte("x\n"))
+	}
+	// let the first message reach the wedged write
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	err := h.CloseTimeout(50 * time.Millisecond)
+	if err == nil {
+		t.Fatal("CloseTimeout returned nil with a wedged writer")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CloseTimeout took %s", elapsed)
+	}
+	if !strings.Contains(err.Error(), "4 messages dropped") {
+		t.Errorf("err = %v, want 4 messages dropped", err)
+	}
+	if h.Dropped() != 4 {
+		t.Errorf("Dropped = %d, want 4", h.Dropped())
+	}
+}
+
+func TestAsyncCloseTimeoutBlockedWriter(t *testing.T) {
+	inner := newTestHandler(t, nil)
+	release := wedge(inner)
+	h := NewAsyncHandler(inner, 1)
+	defer func() {
+		release()
+		<-h.done
+	}()
+
+	// one message in the wedged write, one in the queue, the rest blocked
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Write([]byte("x\n"))
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan error)
+	go func() { done <- h.CloseTimeout(100 * time.Millisecond) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("CloseTimeout returned nil with a wedged writer")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("CloseTimeout blocked by writers waiting on a full queue")
+	}
+	wg.Wait()
+}
+
+func TestAsyncFlushAfterAbort(t *testing.T) {
+	inner := newTestHandler(t, nil)
+	release := wedge(inner)
+	h := NewAsyncHandler(inner, 8)
+	h.Write([]byte("x\n"))
+	time.Sleep(20 * time.Millisecond)
+
+	flushed := make(chan struct{})
+	go func() {
+		h.Flush()
+		close(flushed)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := h.CloseTimeout(20 * time.Millisecond); err == nil {
+		t.Fatal("CloseTimeout returned nil with a wedged writer")
+	}
+	release()
+	select {
+	case <-flushed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Flush queued before an aborted Close never returned")
+	}
+	<-h.done
+}
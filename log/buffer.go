@@ -0,0 +1,102 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// BufferedHandler is a RotateHandler whose writes are held in memory and
+// written to the file every FlushInterval, or sooner when the buffer fills.
+// Rotation still sees every Write, and Flush and Close drain the buffer.
+type BufferedHandler struct {
+	*RotateHandler
+	FlushInterval time.Duration
+	// FlushBytes, if set, writes the buffer out as soon as it holds more
+	// than that many bytes, without waiting for FlushInterval
+	FlushBytes int
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewBufferedHandler buffers up to size bytes of writes to inner, flushing
+// them every interval.
+func NewBufferedHandler(inner *RotateHandler, size int, interval time.Duration) *BufferedHandler {
+	inner.mw.SetBufferSize(size)
+	return &BufferedHandler{
+		RotateHandler: inner,
+		FlushInterval: interval,
+	}
+}
+
+func (h *BufferedHandler) Write(data []byte) (int, error) {
+	n, err := h.RotateHandler.Write(data)
+	h.flushOver()
+	return n, err
+}
+
+func (h *BufferedHandler) WriteLines(lines [][]byte) (int, error) {
+	n, err := h.RotateHandler.WriteLines(lines)
+	h.flushOver()
+	return n, err
+}
+
+func (h *BufferedHandler) WriteString(s string) (int, error) {
+	n, err := h.RotateHandler.WriteString(s)
+	h.flushOver()
+	return n, err
+}
+
+// flushOver writes the buffer out if it holds more than FlushBytes.
+func (h *BufferedHandler) flushOver() {
+	if h.FlushBytes > 0 {
+		if err := h.mw.flushOver(h.FlushBytes); err != nil {
+			h.reportError(err)
+		}
+	}
+}
+
+func (h *BufferedHandler) Init() {
+	h.RotateHandler.Init()
+	h.startFlusher()
+}
+
+func (h *BufferedHandler) InitE() error {
+	if err := h.RotateHandler.InitE(); err != nil {
+		return err
+	}
+	h.startFlusher()
+	return nil
+}
+
+func (h *BufferedHandler) startFlusher() {
+	if h.FlushInterval <= 0 || h.stop != nil {
+		return
+	}
+	h.stop = make(chan struct{})
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(h.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.mw.Flush()
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background flush and closes the file, writing out any
+// buffered data first.
+func (h *BufferedHandler) Close() {
+	if h.stop != nil {
+		close(h.stop)
+		h.wg.Wait()
+		h.stop = nil
+	}
+	h.RotateHandler.Close()
+}
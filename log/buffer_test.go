@@ -0,0 +1,111 @@
+package log
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestBufferedHandler returns an initialized BufferedHandler in a
+// temporary directory. The test must Close it.
+func newTestBufferedHandler(t testing.TB, size int, interval time.Duration) *BufferedHandler {
+	t.Helper()
+	h := NewBufferedHandler(NewDefaultHandler(filepath.Join(t.TempDir(), "test.log")), size, interval)
+	if err := h.InitE(); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestBufferedCloseDrains(t *testing.T) {
+	h := newTestBufferedHandler(t, 64<<10, time.Hour)
+	for i := 0; i < 100; i++ {
+		h.Write([]byte("buffered line\n"))
+	}
+	if got := readFile(t, h.FilePath); got != "" {
+		t.Errorf("file = %q before flushing, want nothing", got)
+	}
+	h.Close()
+	if got := countLinesIn(readFile(t, h.FilePath)); got != 100 {
+		t.Errorf("%d lines after Close, want 100", got)
+	}
+}
+
+func TestBufferedFlushInterval(t *testing.T) {
+	h := newTestBufferedHandler(t, 64<<10, 10*time.Millisecond)
+	defer h.Close()
+	h.Write([]byte("line\n"))
+	deadline := time.Now().Add(5 * time.Second)
+	for readFile(t, h.FilePath) != "line\n" {
+		if time.Now().After(deadline) {
+			t.Fatal("buffer not flushed by the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func BenchmarkUnbufferedWrite(b *testing.B) {
+	h := NewDefaultHandler(filepath.Join(b.TempDir(), "test.log"))
+	if err := h.InitE(); err != nil {
+		b.Fatal(err)
+	}
+	defer h.Close()
+	line := []byte("a typical log line of some length\n")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Write(line)
+	}
+}
+
+func BenchmarkBufferedWrite(b *testing.B) {
+	h := newTestBufferedHandler(b, 64<<10, time.Second)
+	defer h.Close()
+	line := []byte("a typical log line of some length\n")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Write(line)
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	h := NewDefaultHandler(filepath.Join(t.TempDir(), "test.log"))
+	h.mw.SetBufferSize(4096)
+	h.SyncInterval = 10 * time.Millisecond
+	if err := h.InitE(); err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("line\n"))
+	// no Flush: only the sync loop can get the line to the file
+	deadline := time.Now().Add(5 * time.Second)
+	for readFile(t, h.FilePath) != "line\n" {
+		if time.Now().After(deadline) {
+			t.Fatal("not synced by SyncInterval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	done := h.syncDone
+	h.Close()
+	select {
+	case <-done:
+	default:
+		t.Error("sync loop still running after Close")
+	}
+	if h.syncStop != nil {
+		t.Error("sync loop not reset by Close")
+	}
+}
+
+func TestBufferedFlushBytes(t *testing.T) {
+	h := newTestBufferedHandler(t, 64<<10, time.Hour)
+	defer h.Close()
+	h.FlushBytes = 10
+	h.Write([]byte("123456789\n"))
+	if got := readFile(t, h.FilePath); got != "" {
+		t.Errorf("file = %q at FlushBytes, want nothing yet", got)
+	}
+	h.Write([]byte("a\n"))
+	if got := readFile(t, h.FilePath); got != "123456789\na\n" {
+		t.Errorf("file = %q past FlushBytes, want both lines", got)
+	}
+}
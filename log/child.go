@@ -0,0 +1,41 @@
+package log
+
+import (
+	"log"
+	"strings"
+)
+
+// Child returns a logger named "<name>.<suffix>" writing to l's file with
+// l's format, options and filter. Its level follows l's until SetLevel is
+// called on the child.
+func (l *Vlogger) Child(suffix string) *Vlogger {
+	name := l.Name + "." + suffix
+	prefix := l.Logger.Prefix()
+	if prefix != "" {
+		prefix = strings.ToLower(name) + ":"
+	}
+	c := &Vlogger{
+		Logger:         log.New(l.Logger.Writer(), prefix, l.Logger.Flags()),
+		Name:           name,
+		FilePath:       l.FilePath,
+		HandleMode:     l.HandleMode,
+		Format:         l.Format,
+		Caller:         l.Caller,
+		CallerSkip:     l.CallerSkip,
+		Sampler:        l.Sampler,
+		MaxMessageSize: l.MaxMessageSize,
+		timeLayout:     l.timeLayout,
+		utc:            l.utc,
+		discard:        l.discard,
+		out:            l.out,
+		handler:        l.handler,
+		parent:         l,
+	}
+	if f, _ := l.filter.Load().(*filter); f != nil {
+		c.filter.Store(f)
+	}
+	if l.dedup != nil {
+		c.dedup = &dedup{timeout: l.dedup.timeout}
+	}
+	return c
+}
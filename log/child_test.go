@@ -0,0 +1,57 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChildName(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("App", &buf)
+	l.SetFlags(0)
+	c := l.Child("db")
+	gc := c.Child("pool")
+	if c.Name != "App.db" || gc.Name != "App.db.pool" {
+		t.Errorf("names = %q, %q", c.Name, gc.Name)
+	}
+	l.Info("parent")
+	c.Info("child")
+	gc.Info("grandchild")
+
+	want := "app:INFO: parent\napp.db:INFO: child\napp.db.pool:INFO: grandchild\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestChildLevel(t *testing.T) {
+	l := NewWriter("app", &bytes.Buffer{})
+	c := l.Child("db")
+	gc := c.Child("pool")
+
+	l.SetLevel(LevelWarn)
+	if c.GetLevel() != LevelWarn || gc.GetLevel() != LevelWarn {
+		t.Errorf("children at %d and %d, want the parent's %d", c.GetLevel(), gc.GetLevel(), LevelWarn)
+	}
+	c.SetLevel(LevelDebug)
+	l.SetLevel(LevelError)
+	if c.GetLevel() != LevelDebug {
+		t.Errorf("overridden child at %d, want %d", c.GetLevel(), LevelDebug)
+	}
+	// the grandchild follows its own parent, now overridden
+	if gc.GetLevel() != LevelDebug {
+		t.Errorf("grandchild at %d, want %d", gc.GetLevel(), LevelDebug)
+	}
+}
+
+func TestChildCloseKeepsFile(t *testing.T) {
+	h := newTestHandler(t, nil)
+	l := NewWithHandler("app", h)
+	c := l.Child("db")
+	c.Info("child")
+	c.Close()
+	l.Info("parent")
+	if got := countLinesIn(readFile(t, h.FilePath)); got != 2 {
+		t.Errorf("%d lines, want 2: closing the child closed the file", got)
+	}
+}
@@ -0,0 +1,171 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Compressor compresses rotated files, see RotateHandler.Compressor.
+type Compressor interface {
+	// Ext is the extension added to compressed files, such as ".gz"
+	Ext() string
+	// Compress writes a compressed copy of src to dst
+	Compress(dst, src string) error
+}
+
+// Decompressor is implemented by Compressors whose archives OpenArchive can
+// read.
+type Decompressor interface {
+	Decompress(r io.Reader) (io.ReadCloser, error)
+}
+
+// Gzip is the default Compressor.
+var Gzip Compressor = gzipCompressor{}
+
+var compressors = struct {
+	sync.RWMutex
+	byExt map[string]Compressor
+}{byExt: map[string]Compressor{".gz": Gzip}}
+
+// RegisterCompressor makes c's archives known to OpenArchive and to the
+// cleanup of rotated files, replacing any Compressor with the same Ext.
+func RegisterCompressor(c Compressor) {
+	compressors.Lock()
+	defer compressors.Unlock()
+	compressors.byExt[c.Ext()] = c
+}
+
+// compressorExts returns the extensions of the registered compressors.
+func compressorExts() []string {
+	compressors.RLock()
+	defer compressors.RUnlock()
+	exts := make([]string, 0, len(compressors.byExt))
+	for ext := range compressors.byExt {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// compressorFor returns the registered compressor for path's extension.
+func compressorFor(path string) (Compressor, bool) {
+	compressors.RLock()
+	defer compressors.RUnlock()
+	for ext, c := range compressors.byExt {
+		if strings.HasSuffix(path, ext) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// archiveExts returns the extensions w's rotated files may have: those of
+// the registered compressors and of w.Compressor, registered or not.
+func (w *RotateHandler) archiveExts() []string {
+	exts := compressorExts()
+	if w.Compressor == nil {
+		return exts
+	}
+	ext := w.Compressor.Ext()
+	for _, e := range exts {
+		if e == ext {
+			return exts
+		}
+	}
+	return append(exts, ext)
+}
+
+// extPattern matches any of exts, for rotatedPattern.
+func extPattern(exts []string) string {
+	for i, ext := range exts {
+		exts[i] = regexp.QuoteMeta(ext)
+	}
+	return "(" + strings.Join(exts, "|") + ")?"
+}
+
+// compressFile compresses src into src+c.Ext(), removing src once the
+// archive is complete. On failure the partial archive is removed and src is
+// kept.
+func compressFile(c Compressor, src string) error {
+	dst := src + c.Ext()
+	if err := c.Compress(dst, src); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("compress: %s", err)
+	}
+	return os.Remove(src)
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Ext() string { return ".gz" }
+
+func (gzipCompressor) Compress(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (gzipCompressor) Decompress(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// OpenArchive opens a rotated file for reading, decompressing it if its name
+// ends in the extension of a registered Compressor.
+func OpenArchive(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := compressorFor(path)
+	if !ok {
+		return f, nil
+	}
+	d, ok := c.(Decompressor)
+	if !ok {
+		f.Close()
+		return nil, fmt.Errorf("open archive: no decompressor for %s", c.Ext())
+	}
+	r, err := d.Decompress(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open archive: %s", err)
+	}
+	return &archiveFile{ReadCloser: r, f: f}, nil
+}
+
+// archiveFile closes both the decompressing reader and the file under it.
+type archiveFile struct {
+	io.ReadCloser
+	f *os.File
+}
+
+func (a *archiveFile) Close() error {
+	err := a.ReadCloser.Close()
+	if ferr := a.f.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
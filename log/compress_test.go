@@ -0,0 +1,251 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.Compress = true
+	})
+	h.Write([]byte("first line\n"))
+	h.Write([]byte("second line\n"))
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	h.waitAfterRotate()
+
+	got := archives(t, h)
+	if len(got) != 1 || !strings.HasSuffix(got[0], ".gz") {
+		t.Fatalf("archives = %v, want one .gz", got)
+	}
+	f, err := os.Open(got[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "first line\nsecond line\n" {
+		t.Errorf("decompressed = %q", b)
+	}
+	if !h.isRotated(h.FilePath, got[0]) || !h.isRotated(h.FilePath, strings.TrimSuffix(got[0], ".gz")) {
+		t.Error("cleanup does not match both compressed and plain archives")
+	}
+}
+
+func TestCompressWithMaxBackups(t *testing.T) {
+	var mu sync.Mutex
+	var errs []error
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxSize = 10
+		h.Rotatable = true
+		h.Compress = true
+		h.MaxBackups = 3
+		h.OnError = func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		}
+	})
+	for i := 0; i < 40; i++ {
+		if _, err := h.Write([]byte("1234567\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, err := range errs {
+		t.Error(err)
+	}
+	got := archives(t, h)
+	if len(got) != 3 {
+		t.Fatalf("archives = %v, want 3", got)
+	}
+	for _, f := range got {
+		if !strings.HasSuffix(f, ".gz") {
+			t.Errorf("%s is not compressed", f)
+		}
+	}
+}
+
+// copyCompressor "compresses" by copying, under an extension nobody
+// registered.
+type copyCompressor struct{}
+
+func (copyCompressor) Ext() string { return ".z" }
+
+func (copyCompressor) Compress(dst, src string) error {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, b, 0644)
+}
+
+func newCopyCompressHandler(t *testing.T, maxBackups int) *RotateHandler {
+	return newTestHandler(t, func(h *RotateHandler) {
+		h.MaxSize = 10
+		h.Rotatable = true
+		h.Compress = true
+		h.Compressor = copyCompressor{}
+		h.MaxBackups = maxBackups
+	})
+}
+
+func TestUnregisteredCompressorCleanup(t *testing.T) {
+	h := newCopyCompressHandler(t, 2)
+	for i := 0; i < 20; i++ {
+		h.Write([]byte("1234567\n"))
+	}
+	h.Close()
+
+	got := archives(t, h)
+	if len(got) != 2 {
+		t.Fatalf("archives = %v, want 2", got)
+	}
+	for _, f := range got {
+		if !strings.HasSuffix(f, ".z") {
+			t.Errorf("%s is not compressed", f)
+		}
+	}
+}
+
+func TestUnregisteredCompressorNumbering(t *testing.T) {
+	h := newCopyCompressHandler(t, 0)
+	// a number taken by an archive of the custom compressor is skipped
+	taken := h.rotatedName(h.FilePath, h.timeNow(), 1) + ".z"
+	if err := ioutil.WriteFile(taken, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the third line finds the file past MaxSize
+	for i := 0; i < 3; i++ {
+		h.Write([]byte("1234567\n"))
+	}
+	h.Close()
+
+	if got := readFile(t, taken); got != "" {
+		t.Errorf("%s was overwritten with %q", taken, got)
+	}
+	next := h.rotatedName(h.FilePath, h.timeNow(), 2) + ".z"
+	if got := readFile(t, next); got != "1234567\n1234567\n" {
+		t.Errorf("%s = %q", next, got)
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		h := newTestHandler(t, func(h *RotateHandler) {
+			h.Compress = compress
+		})
+		h.Write([]byte("archived\n"))
+		if err := h.DoRotate(); err != nil {
+			t.Fatal(err)
+		}
+		h.waitAfterRotate()
+
+		got := archives(t, h)
+		if len(got) != 1 {
+			t.Fatalf("Compress=%v: archives = %v, want one", compress, got)
+		}
+		if strings.HasSuffix(got[0], ".gz") != compress {
+			t.Errorf("Compress=%v: archive %s", compress, got[0])
+		}
+		r, err := OpenArchive(got[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "archived\n" {
+			t.Errorf("Compress=%v: OpenArchive read %q", compress, b)
+		}
+	}
+}
+
+// reverseCompressor "compresses" by reversing the bytes, so an archive read
+// back unchanged is caught.
+type reverseCompressor struct{}
+
+func (reverseCompressor) Ext() string { return ".rev" }
+
+func (reverseCompressor) Compress(dst, src string) error {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, reversed(b), 0644)
+}
+
+func (reverseCompressor) Decompress(r io.Reader) (io.ReadCloser, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(reversed(b))), nil
+}
+
+func reversed(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[len(b)-1-i] = c
+	}
+	return out
+}
+
+func TestRegisteredCompressor(t *testing.T) {
+	RegisterCompressor(reverseCompressor{})
+	t.Cleanup(func() {
+		compressors.Lock()
+		delete(compressors.byExt, reverseCompressor{}.Ext())
+		compressors.Unlock()
+	})
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.Compress = true
+		h.Compressor = reverseCompressor{}
+	})
+	h.Write([]byte("first line\n"))
+	h.Write([]byte("second line\n"))
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	h.waitAfterRotate()
+
+	got := archives(t, h)
+	if len(got) != 1 || !strings.HasSuffix(got[0], ".rev") {
+		t.Fatalf("archives = %v, want one .rev", got)
+	}
+	if raw := readFile(t, got[0]); raw == "first line\nsecond line\n" {
+		t.Fatalf("%s was not compressed", got[0])
+	}
+	r, err := OpenArchive(got[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "first line\nsecond line\n" {
+		t.Errorf("OpenArchive read %q", b)
+	}
+}
@@ -0,0 +1,150 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// modeNames are the names of the RotateMode constants in config files.
+var modeNames = map[string]int{
+	"none":    RotateModeNoRotate,
+	"week":    RotateModeWeek,
+	"month":   RotateModeMonth,
+	"16M":     RotateMode16M,
+	"256M":    RotateMode256M,
+	"million": RotateModeMillion,
+	"hour":    RotateModeHour,
+}
+
+// LoggerConfig declares one logger in a file read by LoadConfig. Mode names
+// a RotateMode constant ("none", "week", "month", "16M", "256M", "million"
+// or "hour"); MaxSize, MaxLines and MaxDays override the mode's defaults.
+type LoggerConfig struct {
+	Name     string `json:"name"`
+	Mode     string `json:"mode"`
+	MaxSize  string `json:"maxSize"`
+	MaxLines int    `json:"maxLines"`
+	MaxDays  int    `json:"maxDays"`
+	Level    string `json:"level"`
+}
+
+// LoadConfig creates and registers the loggers declared in the JSON array
+// at path, as if by GetLogger. Every entry is checked before any logger is
+// created, and names that are already registered, or being created by
+// GetLogger, are an error. The loggers are registered together once all of
+// them are open; on failure none is, and those already opened are closed.
+func LoadConfig(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var configs []LoggerConfig
+	if err = json.Unmarshal(data, &configs); err != nil {
+		return fmt.Errorf("log config %s: %s", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i, c := range configs {
+		if c.Name == "" {
+			return fmt.Errorf("log config %s: logger %d has no name", path, i)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("log config %s: logger %q already registered", path, c.Name)
+		}
+		seen[c.Name] = true
+	}
+	bose.mu.Lock()
+	err = bose.checkConfig(path, configs)
+	paths := make([]string, len(configs))
+	for i, c := range configs {
+		paths[i] = bose.path(c.Name)
+	}
+	bose.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	handlers := make([]*RotateHandler, len(configs))
+	levels := make([]int, len(configs))
+	for i, c := range configs {
+		if handlers[i], err = c.handler(paths[i]); err != nil {
+			return fmt.Errorf("log config %s: logger %q: %s", path, c.Name, err)
+		}
+		if c.Level != "" {
+			if levels[i], err = ParseLevel(c.Level); err != nil {
+				return fmt.Errorf("log config %s: logger %q: %s", path, c.Name, err)
+			}
+		}
+	}
+
+	// open the files without holding bose.mu, like getLogger
+	loggers := make([]*Vlogger, 0, len(configs))
+	closeAll := func() {
+		for _, l := range loggers {
+			l.Close()
+		}
+	}
+	for i, c := range configs {
+		l, err := newLogger(c.Name, paths[i], modeNames[c.Mode], handlers[i])
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("log config %s: logger %q: %s", path, c.Name, err)
+		}
+		l.SetLevel(levels[i])
+		loggers = append(loggers, l)
+	}
+
+	bose.mu.Lock()
+	// GetLogger may have taken a name while the files were opened
+	if err = bose.checkConfig(path, configs); err == nil {
+		for _, l := range loggers {
+			bose.loggers[l.Name] = l
+		}
+	}
+	bose.mu.Unlock()
+	if err != nil {
+		closeAll()
+	}
+	return err
+}
+
+// checkConfig fails if a logger in configs, read from path, is registered
+// or being created. Must hold mu.
+func (m *manager) checkConfig(path string, configs []LoggerConfig) error {
+	for _, c := range configs {
+		if _, ok := m.loggers[c.Name]; ok {
+			return fmt.Errorf("log config %s: logger %q already registered", path, c.Name)
+		}
+		if _, ok := m.creating[c.Name]; ok {
+			return fmt.Errorf("log config %s: logger %q is being created", path, c.Name)
+		}
+	}
+	return nil
+}
+
+// handler builds the configured, not yet opened, handler for fp.
+func (c LoggerConfig) handler(fp string) (*RotateHandler, error) {
+	mode, ok := modeNames[c.Mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown rotate mode %q", c.Mode)
+	}
+	h := newHandler(fp, mode)
+	if c.MaxSize != "" {
+		size, err := parseSize(c.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+		h.MaxSize = size
+		h.Rotatable = true
+	}
+	if c.MaxLines > 0 {
+		h.MaxLines = c.MaxLines
+		h.Rotatable = true
+	}
+	if c.MaxDays > 0 {
+		h.MaxDays = c.MaxDays
+		h.Rotatable = true
+	}
+	return h, nil
+}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a LoadConfig file holding data.
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log.json")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigNameBeingCreated(t *testing.T) {
+	useLogDir(t)
+	bose.mu.Lock()
+	bose.creating["api"] = &creation{done: make(chan struct{})}
+	bose.mu.Unlock()
+	defer func() {
+		bose.mu.Lock()
+		delete(bose.creating, "api")
+		bose.mu.Unlock()
+	}()
+
+	err := LoadConfig(writeConfig(t, `[{"name": "api", "mode": "none"}]`))
+	if err == nil || !strings.Contains(err.Error(), "being created") {
+		t.Fatalf("LoadConfig = %v, want a being created error", err)
+	}
+	if HasLogger("api") {
+		t.Error("api registered over the logger being created")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := useLogDir(t)
+	err := LoadConfig(writeConfig(t, `[
+		{"name": "api", "mode": "week", "level": "warn"},
+		{"name": "db", "mode": "16M", "maxSize": "1K", "maxDays": 3},
+		{"name": "jobs", "mode": "million", "maxLines": 10}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := GetLogger("api", RotateModeNoRotate)
+	if api.FilePath != filepath.Join(dir, "api.log") || api.HandleMode != RotateModeWeek || api.GetLevel() != LevelWarn {
+		t.Errorf("api: %s mode %d level %d", api.FilePath, api.HandleMode, api.GetLevel())
+	}
+	db := GetLogger("db", RotateModeNoRotate).handler.(*RotateHandler)
+	if db.MaxSize != 1<<10 || db.MaxDays != 3 {
+		t.Errorf("db: MaxSize %d, MaxDays %d", db.MaxSize, db.MaxDays)
+	}
+	jobs := GetLogger("jobs", RotateModeNoRotate).handler.(*RotateHandler)
+	if jobs.MaxLines != 10 {
+		t.Errorf("jobs: MaxLines %d", jobs.MaxLines)
+	}
+
+	api.Info("dropped")
+	api.Warn("kept")
+	Close("api")
+	if got := readFile(t, api.FilePath); strings.Contains(got, "dropped") || !strings.Contains(got, "kept") {
+		t.Errorf("api.log = %q", got)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	useLogDir(t)
+	for _, data := range []string{
+		`[{"name": "a", "mode": "yearly"}]`,
+		`[{"name": "a", "mode": "none", "level": "loud"}]`,
+		`[{"name": "a", "mode": "none", "maxSize": "12X"}]`,
+		`[{"mode": "none"}]`,
+		`[{"name": "a", "mode": "none"}, {"name": "a", "mode": "none"}]`,
+		`{"name": "a"}`,
+	} {
+		if err := LoadConfig(writeConfig(t, data)); err == nil {
+			t.Errorf("%s: no error", data)
+		}
+		if HasLogger("a") {
+			t.Fatalf("%s: a registered despite the error", data)
+		}
+	}
+}
+
+func TestLoadConfigOpenError(t *testing.T) {
+	dir := useLogDir(t)
+	// a directory where db's file should be
+	if err := os.Mkdir(filepath.Join(dir, "db.log"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := LoadConfig(writeConfig(t, `[
+		{"name": "api", "mode": "none"},
+		{"name": "db", "mode": "none"}
+	]`))
+	if err == nil || !strings.Contains(err.Error(), `"db"`) {
+		t.Fatalf("LoadConfig = %v, want an error for db", err)
+	}
+	if HasLogger("api") || HasLogger("db") {
+		t.Fatal("loggers registered despite the error")
+	}
+	// api's file was closed and can be opened again
+	api := GetLogger("api", RotateModeNoRotate)
+	api.Info("ready")
+	if got := readFile(t, api.FilePath); countLinesIn(got) != 1 {
+		t.Errorf("api.log = %q", got)
+	}
+}
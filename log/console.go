@@ -0,0 +1,106 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+var levelColors = map[int]string{
+	LevelDebug: "\x1b[90m",
+	LevelInfo:  "\x1b[32m",
+	LevelWarn:  "\x1b[33m",
+	LevelError: "\x1b[31m",
+	LevelFatal: "\x1b[31m",
+}
+
+const colorReset = "\x1b[0m"
+
+// ConsoleHandler writes to a terminal, coloring the level token of each line.
+type ConsoleHandler struct {
+	mu  sync.Mutex
+	out io.Writer
+	// Color enables ANSI colors; it defaults to whether out is a terminal
+	Color bool
+}
+
+// NewConsoleHandler writes to out, or os.Stderr if out is nil.
+func NewConsoleHandler(out io.Writer) *ConsoleHandler {
+	if out == nil {
+		out = os.Stderr
+	}
+	return &ConsoleHandler{
+		out:   out,
+		Color: isTerminal(out),
+	}
+}
+
+// isTerminal reports whether w is a file attached to a character device.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (h *ConsoleHandler) Write(data []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.out.Write(data)
+}
+
+// WriteLevel writes data with its level token colored.
+func (h *ConsoleHandler) WriteLevel(level int, data []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.Color {
+		return h.out.Write(data)
+	}
+	if _, err := h.out.Write(colorize(data, level)); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// colorize wraps the first token naming level in data, "ERROR:" in text
+// lines or "ERROR" in JSON ones, in its color.
+func colorize(data []byte, level int) []byte {
+	at, end := levelToken(data, LevelName(level))
+	if at < 0 {
+		return data
+	}
+	out := make([]byte, 0, len(data)+len(levelColors[level])+len(colorReset))
+	out = append(out, data[:at]...)
+	out = append(out, levelColors[level]...)
+	out = append(out, data[at:end]...)
+	out = append(out, colorReset...)
+	return append(out, data[end:]...)
+}
+
+func (h *ConsoleHandler) Init() {}
+
+func (h *ConsoleHandler) Flush() {}
+
+func (h *ConsoleHandler) Close() {}
+
+// levelToken returns the bounds of the first name in data followed by ':'
+// or '"', or at < 0 if there is none.
+func levelToken(data []byte, name string) (at, end int) {
+	for from := 0; ; {
+		i := bytes.Index(data[from:], []byte(name))
+		if i < 0 {
+			return -1, -1
+		}
+		at, end = from+i, from+i+len(name)
+		if end < len(data) && (data[end] == ':' || data[end] == '"') {
+			return at, end
+		}
+		from = end
+	}
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConsoleNoColorOnBuffer(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewConsoleHandler(&buf)
+	if h.Color {
+		t.Fatal("Color enabled for a plain buffer")
+	}
+	l := NewWithHandler("app", h)
+	l.Error("boom")
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("output %q has color codes", buf.String())
+	}
+	if !strings.Contains(buf.String(), "ERROR: boom") {
+		t.Errorf("output = %q", buf.String())
+	}
+}
+
+func TestConsoleColor(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewConsoleHandler(&buf)
+	h.Color = true
+	l := NewWithHandler("app", h)
+	l.SetFlags(0)
+
+	l.Warn("text with INFO: inside")
+	want := "app:" + levelColors[LevelWarn] + "WARN" + colorReset + ": text with INFO: inside\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	l.Println("no level")
+	if buf.String() != "app:no level\n" {
+		t.Errorf("Println output = %q, want it uncolored", buf.String())
+	}
+}
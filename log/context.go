@@ -0,0 +1,69 @@
+package log
+
+import (
+	"context"
+	"fmt"
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// WithContext returns a copy of ctx carrying l.
+func WithContext(ctx context.Context, l *Vlogger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the logger carried by ctx, or the Default one.
+func FromContext(ctx context.Context) *Vlogger {
+	if l, ok := ctx.Value(loggerKey).(*Vlogger); ok && l != nil {
+		return l
+	}
+	return Default()
+}
+
+// WithRequestID returns a copy of ctx carrying a request id, which the Ctx
+// logging functions add to each message as the request_id field.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request id carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func contextFields(ctx context.Context) Fields {
+	if id := RequestID(ctx); id != "" {
+		return Fields{"request_id": id}
+	}
+	return nil
+}
+
+func DebugCtx(ctx context.Context, v ...interface{}) {
+	if l := FromContext(ctx); l.enabled(LevelDebug) {
+		l.output(LevelDebug, fmt.Sprintln(v...), contextFields(ctx))
+	}
+}
+
+func InfoCtx(ctx context.Context, v ...interface{}) {
+	if l := FromContext(ctx); l.enabled(LevelInfo) {
+		l.output(LevelInfo, fmt.Sprintln(v...), contextFields(ctx))
+	}
+}
+
+func WarnCtx(ctx context.Context, v ...interface{}) {
+	if l := FromContext(ctx); l.enabled(LevelWarn) {
+		l.output(LevelWarn, fmt.Sprintln(v...), contextFields(ctx))
+	}
+}
+
+func ErrorCtx(ctx context.Context, v ...interface{}) {
+	if l := FromContext(ctx); l.enabled(LevelError) {
+		l.output(LevelError, fmt.Sprintln(v...), contextFields(ctx))
+	}
+}
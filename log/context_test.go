@@ -0,0 +1,44 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	ctx := WithRequestID(WithContext(context.Background(), l), "r-42")
+	if FromContext(ctx) != l {
+		t.Fatal("FromContext did not return the logger")
+	}
+	InfoCtx(ctx, "handled")
+	ErrorCtx(WithContext(context.Background(), l), "no id")
+
+	want := "app:INFO: handled request_id=r-42\napp:ERROR: no id\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestContextWithoutLogger(t *testing.T) {
+	saveDefault(t)
+	var buf bytes.Buffer
+	def := NewWriter("default", &buf)
+	def.SetFlags(0)
+	SetDefault(def)
+
+	ctx := WithRequestID(context.Background(), "r-1")
+	if FromContext(ctx) != def {
+		t.Fatal("FromContext without a logger is not Default")
+	}
+	if RequestID(context.Background()) != "" {
+		t.Error("request id without one set")
+	}
+	WarnCtx(ctx, "fallback")
+	if want := "default:WARN: fallback request_id=r-1\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
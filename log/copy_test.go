@@ -0,0 +1,76 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// failRename makes rename fail with errno until the test ends.
+func failRename(t *testing.T, errno syscall.Errno) {
+	t.Helper()
+	rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errno}
+	}
+	t.Cleanup(func() { rename = os.Rename })
+}
+
+func TestRotateCrossDevice(t *testing.T) {
+	h := newTestHandler(t, nil)
+	failRename(t, syscall.EXDEV)
+	h.Write([]byte("before\n"))
+	before, err := os.Stat(h.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("after\n"))
+	h.Flush()
+
+	got := archives(t, h)
+	if len(got) != 1 {
+		t.Fatalf("archives = %v, want 1", got)
+	}
+	if content := readFile(t, got[0]); content != "before\n" {
+		t.Errorf("archive = %q, want the rotated line", content)
+	}
+	if content := readFile(t, h.FilePath); content != "after\n" {
+		t.Errorf("file = %q, want only the new line", content)
+	}
+	after, err := os.Stat(h.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("copy and truncate replaced the file's inode")
+	}
+}
+
+func TestRotateRenameError(t *testing.T) {
+	h := newTestHandler(t, nil)
+	failRename(t, syscall.EACCES)
+	h.Write([]byte("line\n"))
+
+	err := h.DoRotate()
+	var rerr *RotateError
+	if !errors.As(err, &rerr) || rerr.Op != "rename" || !errors.Is(err, syscall.EACCES) {
+		t.Fatalf("DoRotate = %v, want a rename RotateError", err)
+	}
+	if got := archives(t, h); len(got) != 0 {
+		t.Errorf("archives = %v after a failed rename", got)
+	}
+	if _, err := h.Write([]byte("more\n")); err != nil {
+		t.Fatal(err)
+	}
+	h.Flush()
+	if got := readFile(t, h.FilePath); got != "line\nmore\n" {
+		t.Errorf("file = %q, want writes to go on", got)
+	}
+}
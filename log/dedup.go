@@ -0,0 +1,71 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// dedup collapses runs of identical messages: the first is written at once,
+// the repeats only as a count, written when a different message arrives,
+// the timeout passes or the logger is flushed.
+type dedup struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	last    *message
+	lastKey string
+	repeats int
+	timer   *time.Timer
+}
+
+// WithDedup collapses consecutive identical messages into one followed by
+// "(repeated N times)", written at the latest timeout after the last repeat.
+func WithDedup(timeout time.Duration) Option {
+	return func(c *config) {
+		c.dedup = &dedup{timeout: timeout}
+	}
+}
+
+func dedupKey(m message) string {
+	return fmt.Sprintf("%d %s %s", m.level, m.msg, formatFields(m.fields))
+}
+
+func (d *dedup) output(l *Vlogger, m message) {
+	key := dedupKey(m)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.last != nil && key == d.lastKey {
+		d.repeats++
+		if d.timer == nil && d.timeout > 0 {
+			d.timer = time.AfterFunc(d.timeout, func() { d.flush(l) })
+		}
+		return
+	}
+	d.writeRepeats(l)
+	d.last, d.lastKey = &m, key
+	l.write(m)
+}
+
+// flush writes the pending repeat count, if any.
+func (d *dedup) flush(l *Vlogger) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.writeRepeats(l)
+	d.last, d.lastKey = nil, ""
+}
+
+// writeRepeats writes the repeat count of the last message. Must hold mu.
+func (d *dedup) writeRepeats(l *Vlogger) {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.repeats == 0 {
+		return
+	}
+	m := *d.last
+	m.msg = fmt.Sprintf("%s (repeated %d times)", m.msg, d.repeats)
+	d.repeats = 0
+	l.write(m)
+}
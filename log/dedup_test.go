@@ -0,0 +1,67 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDedupLogger(timeout time.Duration) (*Vlogger, *MemoryHandler) {
+	mem := NewMemoryHandler()
+	l := NewWriter("app", mem, WithDedup(timeout))
+	l.SetFlags(0)
+	return l, mem
+}
+
+func TestDedup(t *testing.T) {
+	l, mem := newDedupLogger(0)
+	for i := 0; i < 3; i++ {
+		l.Info("same")
+	}
+	l.Warn("same") // another level is another message
+	l.Warn("same")
+	l.Info("other")
+
+	want := []string{
+		"app:INFO: same",
+		"app:INFO: same (repeated 2 times)",
+		"app:WARN: same",
+		"app:WARN: same (repeated 1 times)",
+		"app:INFO: other",
+	}
+	if got := mem.Lines(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
+func TestDedupFlushOnClose(t *testing.T) {
+	l, mem := newDedupLogger(time.Hour)
+	l.Error("boom")
+	l.Error("boom")
+	if n := len(mem.Lines()); n != 1 {
+		t.Fatalf("%d lines before Close, want 1", n)
+	}
+	l.Close()
+	if got := mem.Lines(); len(got) != 2 || got[1] != "app:ERROR: boom (repeated 1 times)" {
+		t.Errorf("lines after Close = %q", got)
+	}
+}
+
+func TestDedupTimeout(t *testing.T) {
+	l, mem := newDedupLogger(10 * time.Millisecond)
+	l.Info("tick")
+	l.Info("tick")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(mem.Lines()) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("repeat count not written after the timeout: %q", mem.Lines())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// a repeat after the count was written starts a new run
+	l.Info("tick")
+	l.Flush()
+	if got := mem.Lines(); len(got) != 3 || got[2] != "app:INFO: tick" {
+		t.Errorf("lines = %q", got)
+	}
+}
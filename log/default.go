@@ -0,0 +1,124 @@
+package log
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// defaultName is the name of the managed logger Default switches to once
+// SetLogDir was called.
+const defaultName = "default"
+
+// stderrLogger is the Default logger until a log directory is set.
+var stderrLogger = newStderrLogger()
+
+func newStderrLogger() *Vlogger {
+	l := newVlogger(defaultName, os.Stderr)
+	l.SetPrefix("")
+	l.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	l.HandleMode = RotateModeCustom
+	return l
+}
+
+var std struct {
+	sync.Mutex
+	l        *Vlogger
+	explicit bool // set by SetDefault, never replaced automatically
+	dirSet   bool
+}
+
+// Default returns the logger used by the package-level logging functions.
+// It writes to stderr until SetLogDir is called, then to default.log in
+// that directory, unless SetDefault chose another logger.
+func Default() *Vlogger {
+	std.Lock()
+	defer std.Unlock()
+	if std.l == nil {
+		std.l = stderrLogger
+		if std.dirSet {
+			if l, err := GetLoggerE(defaultName, RotateModeNoRotate); err == nil {
+				std.l = l
+			} else {
+				internalErrorf("log: default logger: %s\n", err)
+			}
+		}
+	}
+	return std.l
+}
+
+// SetDefault makes l the logger used by the package-level logging functions.
+func SetDefault(l *Vlogger) {
+	std.Lock()
+	defer std.Unlock()
+	std.l = l
+	std.explicit = l != nil
+}
+
+// resetDefault makes Default pick up a newly set log directory, or reopen
+// default.log after the managed loggers were closed.
+func resetDefault(dirSet bool) {
+	std.Lock()
+	defer std.Unlock()
+	std.dirSet = std.dirSet || dirSet
+	if !std.explicit {
+		std.l = nil
+	}
+}
+
+// There is no package-level Debug, the name is taken by the Debug flag.
+
+func Debugf(format string, v ...interface{}) {
+	if l := Default(); l.enabled(LevelDebug) {
+		l.output(LevelDebug, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func Info(v ...interface{}) {
+	if l := Default(); l.enabled(LevelInfo) {
+		l.output(LevelInfo, fmt.Sprintln(v...), nil)
+	}
+}
+
+func Infof(format string, v ...interface{}) {
+	if l := Default(); l.enabled(LevelInfo) {
+		l.output(LevelInfo, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func Warn(v ...interface{}) {
+	if l := Default(); l.enabled(LevelWarn) {
+		l.output(LevelWarn, fmt.Sprintln(v...), nil)
+	}
+}
+
+func Warnf(format string, v ...interface{}) {
+	if l := Default(); l.enabled(LevelWarn) {
+		l.output(LevelWarn, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func Error(v ...interface{}) {
+	if l := Default(); l.enabled(LevelError) {
+		l.output(LevelError, fmt.Sprintln(v...), nil)
+	}
+}
+
+func Errorf(format string, v ...interface{}) {
+	if l := Default(); l.enabled(LevelError) {
+		l.output(LevelError, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func Fatal(v ...interface{}) {
+	l := Default()
+	l.output(LevelFatal, fmt.Sprintln(v...), nil)
+	l.exit()
+}
+
+func Fatalf(format string, v ...interface{}) {
+	l := Default()
+	l.output(LevelFatal, fmt.Sprintf(format, v...), nil)
+	l.exit()
+}
@@ -0,0 +1,82 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDefaultStderrThenFile(t *testing.T) {
+	saveDefault(t)
+	std.Lock()
+	std.l, std.explicit, std.dirSet = nil, false, false
+	std.Unlock()
+	var stderr bytes.Buffer
+	stderrLogger.SetOutput(&stderr)
+	defer stderrLogger.SetOutput(os.Stderr)
+
+	if Default() != stderrLogger {
+		t.Fatal("Default is not the stderr logger before SetLogDir")
+	}
+	Info("to stderr")
+	if !strings.Contains(stderr.String(), "INFO: to stderr") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+
+	dir := useLogDir(t)
+	Errorf("to %s", "file")
+	if l := Default(); l.FilePath != filepath.Join(dir, "default.log") {
+		t.Fatalf("Default writes to %q after SetLogDir", l.FilePath)
+	}
+	Default().Flush()
+	if got := readFile(t, filepath.Join(dir, "default.log")); !strings.Contains(got, "ERROR: to file") {
+		t.Errorf("default.log = %q", got)
+	}
+	if strings.Contains(stderr.String(), "to file") {
+		t.Error("still writing to stderr after SetLogDir")
+	}
+}
+
+func TestDefaultConcurrent(t *testing.T) {
+	saveDefault(t)
+	dir := useLogDir(t)
+	std.Lock()
+	std.l = nil
+	std.Unlock()
+
+	var wg sync.WaitGroup
+	loggers := make([]*Vlogger, 8)
+	for i := range loggers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			loggers[i] = Default()
+			Warn("concurrent")
+		}(i)
+	}
+	wg.Wait()
+	for _, l := range loggers {
+		if l != loggers[0] {
+			t.Fatal("Default created more than one logger")
+		}
+	}
+	if got := countLinesIn(readFile(t, filepath.Join(dir, "default.log"))); got != 8 {
+		t.Errorf("%d lines in default.log, want 8", got)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	saveDefault(t)
+	var buf bytes.Buffer
+	l := NewWriter("mine", &buf)
+	l.SetFlags(0)
+	SetDefault(l)
+	useLogDir(t) // an explicit default is kept
+	Warnf("n=%d", 1)
+	if buf.String() != "mine:WARN: n=1\n" {
+		t.Errorf("output = %q", buf.String())
+	}
+}
@@ -0,0 +1,219 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+)
+
+// Fields is key/value context attached to a message.
+type Fields map[string]interface{}
+
+// Entry is a logger with fields attached, created by WithFields. Entries are
+// immutable, so they can be shared and extended freely.
+type Entry struct {
+	logger *Vlogger
+	fields Fields
+	// pooled entries go back to entryPool after logging once
+	pooled bool
+}
+
+func (l *Vlogger) WithFields(fields map[string]interface{}) *Entry {
+	return &Entry{logger: l, fields: mergeFields(nil, fields)}
+}
+
+func (l *Vlogger) WithField(key string, value interface{}) *Entry {
+	return &Entry{logger: l, fields: Fields{key: value}}
+}
+
+// WithFields returns a new entry with fields added to e's, replacing values
+// of existing keys.
+func (e *Entry) WithFields(fields map[string]interface{}) *Entry {
+	return &Entry{logger: e.logger, fields: mergeFields(e.fields, fields)}
+}
+
+func (e *Entry) WithField(key string, value interface{}) *Entry {
+	return e.WithFields(Fields{key: value})
+}
+
+var entryPool = sync.Pool{
+	New: func() interface{} { return &Entry{pooled: true} },
+}
+
+// WithPooledFields is like WithFields but takes the entry from a pool, to
+// keep the garbage of structured logging on hot paths low. The entry goes
+// back to the pool when one of its logging methods returns, so it must log
+// exactly once and must not be kept or used afterwards.
+func (l *Vlogger) WithPooledFields(fields map[string]interface{}) *Entry {
+	e := entryPool.Get().(*Entry)
+	e.logger = l
+	if e.fields == nil {
+		e.fields = make(Fields, len(fields))
+	}
+	for k, v := range fields {
+		e.fields[k] = v
+	}
+	return e
+}
+
+// release returns a pooled entry to the pool.
+func (e *Entry) release() {
+	if !e.pooled {
+		return
+	}
+	if e.logger.dedup != nil {
+		// dedup may still hold the map for the repeat count
+		e.fields = nil
+	} else {
+		for k := range e.fields {
+			delete(e.fields, k)
+		}
+	}
+	e.logger = nil
+	entryPool.Put(e)
+}
+
+func mergeFields(base Fields, add map[string]interface{}) Fields {
+	merged := make(Fields, len(base)+len(add))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range add {
+		merged[k] = v
+	}
+	return merged
+}
+
+// globalFields holds the Fields set by SetGlobalFields.
+var globalFields atomic.Value
+
+// SetGlobalFields adds fields, such as the service name or host, to every
+// message of every logger. Fields of the message itself win on conflict. It
+// replaces the previous global fields; nil removes them.
+func SetGlobalFields(fields map[string]string) {
+	global := make(Fields, len(fields))
+	for k, v := range fields {
+		global[k] = v
+	}
+	globalFields.Store(global)
+}
+
+// withGlobalFields returns fields merged over the global fields.
+func withGlobalFields(fields Fields) Fields {
+	global, _ := globalFields.Load().(Fields)
+	if len(global) == 0 {
+		return fields
+	}
+	return mergeFields(global, fields)
+}
+
+func (e *Entry) Debug(v ...interface{}) {
+	if e.logger.enabled(LevelDebug) {
+		e.logger.output(LevelDebug, fmt.Sprintln(v...), e.fields)
+	}
+	e.release()
+}
+
+func (e *Entry) Debugf(format string, v ...interface{}) {
+	if e.logger.enabled(LevelDebug) {
+		e.logger.output(LevelDebug, fmt.Sprintf(format, v...), e.fields)
+	}
+	e.release()
+}
+
+func (e *Entry) Info(v ...interface{}) {
+	if e.logger.enabled(LevelInfo) {
+		e.logger.output(LevelInfo, fmt.Sprintln(v...), e.fields)
+	}
+	e.release()
+}
+
+func (e *Entry) Infof(format string, v ...interface{}) {
+	if e.logger.enabled(LevelInfo) {
+		e.logger.output(LevelInfo, fmt.Sprintf(format, v...), e.fields)
+	}
+	e.release()
+}
+
+func (e *Entry) Warn(v ...interface{}) {
+	if e.logger.enabled(LevelWarn) {
+		e.logger.output(LevelWarn, fmt.Sprintln(v...), e.fields)
+	}
+	e.release()
+}
+
+func (e *Entry) Warnf(format string, v ...interface{}) {
+	if e.logger.enabled(LevelWarn) {
+		e.logger.output(LevelWarn, fmt.Sprintf(format, v...), e.fields)
+	}
+	e.release()
+}
+
+func (e *Entry) Error(v ...interface{}) {
+	if e.logger.enabled(LevelError) {
+		e.logger.output(LevelError, fmt.Sprintln(v...), e.fields)
+	}
+	e.release()
+}
+
+func (e *Entry) Errorf(format string, v ...interface{}) {
+	if e.logger.enabled(LevelError) {
+		e.logger.output(LevelError, fmt.Sprintf(format, v...), e.fields)
+	}
+	e.release()
+}
+
+// formatFields renders fields as key=value pairs sorted by key, quoting
+// keys and values that contain spaces, quotes, '=' or control characters
+// such as newlines, so each message stays on one line.
+func formatFields(fields Fields) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if needsQuote(k) {
+			b.WriteString(strconv.Quote(k))
+		} else {
+			b.WriteString(k)
+		}
+		b.WriteByte('=')
+		b.WriteString(formatValue(fmt.Sprint(fields[k])))
+	}
+	return b.String()
+}
+
+// formatKV formats one key=val pair like formatFields, without a map.
+func formatKV(key, val string) string {
+	if needsQuote(key) {
+		key = strconv.Quote(key)
+	}
+	return key + "=" + formatValue(val)
+}
+
+func formatValue(s string) string {
+	if s == "" || needsQuote(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// needsQuote reports whether s can't be written bare in a key=value pair.
+func needsQuote(s string) bool {
+	for _, r := range s {
+		if r == ' ' || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
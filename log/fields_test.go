@@ -0,0 +1,93 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestWithFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	l.WithFields(Fields{"user": 42, "b": "x"}).WithField("a", "two words").Info("login")
+
+	want := "app:INFO: login a=\"two words\" b=x user=42\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWithFieldsImmutable(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	base := l.WithField("a", 1)
+	base.WithField("a", 2).WithField("b", 3).Info("child")
+	base.Info("base")
+
+	want := "app:INFO: child a=2 b=3\napp:INFO: base a=1\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestGlobalFields(t *testing.T) {
+	SetGlobalFields(map[string]string{"service": "api", "env": "prod"})
+	defer SetGlobalFields(nil)
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	l.Info("plain")
+	l.WithField("env", "dev").Info("override")
+
+	want := "app:INFO: plain env=prod service=api\napp:INFO: override env=dev service=api\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	l.Format = FormatJSON
+	l.WithField("env", "dev").Info("json")
+	if s := buf.String(); !strings.Contains(s, `"env":"dev"`) || !strings.Contains(s, `"service":"api"`) {
+		t.Errorf("JSON output = %q", s)
+	}
+
+	SetGlobalFields(nil)
+	buf.Reset()
+	l.Format = FormatText
+	l.Info("none")
+	if buf.String() != "app:INFO: none\n" {
+		t.Errorf("output after clearing = %q", buf.String())
+	}
+}
+
+func BenchmarkEntry(b *testing.B) {
+	l := NewWriter("app", ioutil.Discard)
+	fields := map[string]interface{}{"user": 42, "path": "/users"}
+	b.Run("WithFields", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.WithFields(fields).Info("request served")
+		}
+	})
+	b.Run("WithPooledFields", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.WithPooledFields(fields).Info("request served")
+		}
+	})
+}
+
+func TestFieldsEscapeControl(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	l.WithFields(Fields{"a": "two\nlines", "b": "tab\there", "c": `say "hi"`, "d": "bell\x07"}).Info("msg")
+
+	want := `app:INFO: msg a="two\nlines" b="tab\there" c="say \"hi\"" d="bell\a"` + "\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
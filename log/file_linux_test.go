@@ -0,0 +1,40 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDiskFullCooldown(t *testing.T) {
+	var errs []error
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.ErrorCooldown = time.Hour
+		h.OnError = func(err error) { errs = append(errs, err) }
+	})
+	// writes to /dev/full fail with ENOSPC; point FilePath at it too so
+	// the failed write isn't taken for a moved file and retried
+	full, err := os.OpenFile("/dev/full", os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	h.FilePath = full.Name()
+	h.mw.Lock()
+	h.mw.SetLogFile(full)
+	h.mw.Unlock()
+
+	if _, err := h.Write([]byte("line\n")); !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("Write = %v, want ENOSPC", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := h.Write([]byte("line\n")); err != ErrWriteSuspended {
+			t.Fatalf("Write after ENOSPC = %v, want ErrWriteSuspended", err)
+		}
+	}
+	h.waitAfterRotate()
+	if len(errs) != 1 {
+		t.Errorf("%d errors reported, want only the first", len(errs))
+	}
+}
@@ -0,0 +1,24 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package log
+
+import "os"
+
+// chownLike is a no-op where files have no Unix owner.
+func chownLike(fd *os.File, fi os.FileInfo) {}
+
+// isCrossDevice is always false here, the copy fallback is Unix only.
+func isCrossDevice(err error) bool { return false }
+
+// writable checks only the permission bits, there is no access(2) here.
+func writable(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Perm()&0200 == 0 {
+		return &os.PathError{Op: "validate", Path: path, Err: os.ErrPermission}
+	}
+	return nil
+}
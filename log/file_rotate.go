@@ -7,23 +7,36 @@
 package log
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // RotateHandler writes messages by lines limit, file size limit, or time frequency.
 type RotateHandler struct {
+	// first, for 64-bit alignment of its atomic counters
+	stats handlerStats
+
 	mw *MuxWriter
 
 	FilePath string
 	MaxLines int
 	curLines int
+	// LineDelimiter ends the lines counted in an existing file, '\n' if unset
+	LineDelimiter byte
 
 	// Rotate at size
 	MaxSize int
@@ -31,31 +44,272 @@ type RotateHandler struct {
 
 	// Rotate daily
 	MaxDays  int
-	openDate int
+	openDate int // yyyymmdd of the day the file was opened
+
+	// Rotate hourly
+	MaxHours int
+	openHour int // yyyymmddhh of the hour the file was opened
+
+	// Rotate every Interval, on multiples of it since the zero time. It
+	// replaces the daily rotation other modes also do
+	Interval   time.Duration
+	nextRotate time.Time
+
+	// now, if set, replaces time.Now for rotation decisions and names, so
+	// tests can drive time-based rotation with a fake clock
+	now func() time.Time
+
+	// Keep at most MaxBackups rotated files, in every rotation mode. Size
+	// and lines rotation have no age limit, so this is their only cleanup.
+	MaxBackups int
+
+	// MaxTotalSize, if set, caps the bytes used by rotated files; the oldest
+	// are removed until they fit
+	MaxTotalSize int64
+
+	// Compress compresses rotated files in the background, with Compressor
+	// or else Gzip. Register a custom Compressor with RegisterCompressor for
+	// OpenArchive to read its archives
+	Compress   bool
+	Compressor Compressor
+
+	// FileMode is the permission of created log files, 0644 if unset
+	FileMode os.FileMode
+
+	// HeaderFunc, if set, returns a header written at the top of each new
+	// log file, but not to an existing file appended to
+	HeaderFunc func() []byte
+	headerSize int // of the header written to the current file
+
+	// Symlink, if set, is kept pointing at the active log file
+	Symlink string
+
+	// RotateUTC starts new days (and hours) at UTC rather than local
+	// midnight, and dates rotated file names in UTC
+	RotateUTC bool
+
+	// NameFunc, if set, builds the path a file is rotated to from FilePath,
+	// the rotation time and a sequence number starting at 1. MatchFunc must
+	// then report which paths it produced, so old files can be cleaned up.
+	NameFunc  func(base string, t time.Time, seq int) string
+	MatchFunc func(path string) bool
+
+	// Transforms, applied in order, rewrite each line before it is written,
+	// e.g. to redact secrets. Write still reports the original length
+	Transforms []func(line []byte) []byte
+
+	// RateLimit, if set, caps how many lines per second are written; lines
+	// it drops are counted in Stats
+	RateLimit *RateLimiter
+
+	// last rotation number used for the date (or hour) seqKey
+	seqKey  string
+	lastSeq int
+
+	// subscribers receive each written line, see Subscribe
+	subscribers subscribers
+
+	// RecentSize, if set, is how many of the last lines written are kept in
+	// memory for RecentLines
+	RecentSize int
+	recent     recentLines
+
+	// OnRotate is called with the path of each rotated file, before it is
+	// compressed or cleaned up. Rotated files are handled one at a time, in
+	// the order they were rotated
+	OnRotate func(rotatedPath string)
+
+	// OnError, if set, is called with each failure to write, rotate, compress
+	// or clean up, instead of printing it (see SetInternalErrorWriter). It
+	// is called from a background goroutine, in order, once the failing
+	// call has released its locks, so it may log through the same logger
+	OnError func(err error)
+
+	// ErrorCooldown, if set, stops writing to the file for that long after a
+	// write fails, e.g. with ENOSPC, rather than failing again on every line.
+	// Meanwhile lines go to Fallback if set, and are dropped otherwise
+	ErrorCooldown time.Duration
+	Fallback      io.Writer
+	suspendUntil  time.Time
 
 	Rotatable bool
+	// RotateOnStart makes Init archive a non-empty existing file, so each
+	// process starts with a fresh one
+	RotateOnStart bool
+	// startLock is held across each Write and rotation, so the rotation
+	// decision, curLines/curSize and the write to the file change together.
+	// mw's own lock only guards the file against Flush and Close.
 	startLock sync.Mutex
+	// rotations counts completed rotations, read without startLock so
+	// DoRotate can tell whether one finished while it waited
+	rotations uint32
+
+	// signals registered by RotateOnSignal
+	signals []chan os.Signal
+
+	// ReopenInterval, if set, is how often writes check whether FilePath was
+	// removed or replaced, reopening it if so. See Reopen.
+	ReopenInterval time.Duration
+	lastMoveCheck  time.Time
+
+	// FlushMode is how Flush syncs the file to disk, FlushSync or
+	// FlushDataSync
+	FlushMode int
+
+	// SyncInterval, if set, syncs the file to disk that often even when
+	// nothing is written, from Init until Close
+	SyncInterval time.Duration
+	syncMu       sync.Mutex
+	syncStop     chan struct{}
+	syncDone     chan struct{}
+
+	// rotated files, and errors for OnError, waiting for afterRotate,
+	// handled in order by a single goroutine so cleanup never removes a
+	// file still being compressed.
+	// afterIdle is closed when that goroutine runs out of work
+	afterMu    sync.Mutex
+	afterQueue []rotation
+	afterIdle  chan struct{}
 }
 
+// Flush modes, see RotateHandler.FlushMode.
+const (
+	// FlushSync syncs data and metadata with fsync
+	FlushSync = iota
+	// FlushDataSync skips metadata not needed to read the data back, with
+	// fdatasync on Linux; elsewhere it is the same as FlushSync
+	FlushDataSync
+)
+
 // an *os.File writer with locker.
 type MuxWriter struct {
 	sync.Mutex
 	logFile *os.File
+	// buf, when set, holds writes in memory until flushed to logFile
+	buf *bufio.Writer
 }
 
+// ErrWriteSuspended is returned by RotateHandler.Write during the
+// ErrorCooldown after a failed write.
+var ErrWriteSuspended = errors.New("log: writes suspended after a write error")
+
+// ErrWriterNotInitialized is returned when writing to a MuxWriter, or a
+// RotateHandler, before its log file was opened by Init.
+var ErrWriterNotInitialized = errors.New("log: writer not initialized, call Init first")
+
 // write to os.File.
 func (l *MuxWriter) Write(b []byte) (int, error) {
 	l.Lock()
 	defer l.Unlock()
+	if l.logFile == nil {
+		return 0, ErrWriterNotInitialized
+	}
+	if l.buf != nil {
+		return l.buf.Write(b)
+	}
 	return l.logFile.Write(b)
 }
 
+// WriteString is Write for a string, without copying it.
+func (l *MuxWriter) WriteString(s string) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.logFile == nil {
+		return 0, ErrWriterNotInitialized
+	}
+	if l.buf != nil {
+		return l.buf.WriteString(s)
+	}
+	return l.logFile.WriteString(s)
+}
+
 // set os.File in writer.
 func (l *MuxWriter) SetLogFile(fd *os.File) {
 	if l.logFile != nil {
+		l.flush()
 		l.logFile.Close()
 	}
 	l.logFile = fd
+	if l.buf != nil && fd != nil {
+		l.buf.Reset(fd)
+	}
+}
+
+// SetBufferSize buffers up to size bytes of writes in memory. A size of zero
+// or less flushes any buffered data and goes back to writing through.
+func (l *MuxWriter) SetBufferSize(size int) {
+	l.Lock()
+	defer l.Unlock()
+	l.flush()
+	if size <= 0 {
+		l.buf = nil
+		return
+	}
+	// until SetLogFile, the buffer's writer is a nil file erroring on flush
+	l.buf = bufio.NewWriterSize(l.logFile, size)
+}
+
+// Flush writes buffered data to the file.
+func (l *MuxWriter) Flush() error {
+	l.Lock()
+	defer l.Unlock()
+	return l.flush()
+}
+
+// flushOver writes buffered data to the file if there are more than n bytes.
+func (l *MuxWriter) flushOver(n int) error {
+	l.Lock()
+	defer l.Unlock()
+	if l.buf == nil || l.buf.Buffered() <= n {
+		return nil
+	}
+	return l.flush()
+}
+
+// Sync writes buffered data to the file and syncs it to disk.
+func (l *MuxWriter) Sync() error {
+	return l.syncMode(FlushSync)
+}
+
+// syncMode is Sync using the given FlushMode.
+func (l *MuxWriter) syncMode(mode int) error {
+	l.Lock()
+	defer l.Unlock()
+	if err := l.flush(); err != nil {
+		return err
+	}
+	if l.logFile == nil {
+		return ErrWriterNotInitialized
+	}
+	if mode == FlushDataSync {
+		return fdatasync(l.logFile)
+	}
+	return l.logFile.Sync()
+}
+
+// Close writes buffered data to the file and closes it.
+func (l *MuxWriter) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	if l.logFile == nil {
+		return ErrWriterNotInitialized
+	}
+	err := l.flush()
+	if cerr := l.logFile.Close(); err == nil {
+		err = cerr
+	}
+	l.logFile = nil
+	return err
+}
+
+func (l *MuxWriter) flush() error {
+	if l.logFile == nil {
+		return ErrWriterNotInitialized
+	}
+	if l.buf == nil {
+		return nil
+	}
+	return l.buf.Flush()
 }
 
 // create a FileLogWriter returning as LoggerInterface.
@@ -80,6 +334,17 @@ func NewDailyRotateHandler(fp string, days int) *RotateHandler {
 	return w
 }
 
+func NewHourlyRotateHandler(fp string, hours int) *RotateHandler {
+	w := &RotateHandler{
+		FilePath:  fp,
+		MaxHours:  hours,
+		Rotatable: true,
+	}
+	// use MuxWriter instead direct use os.File for lock write when rotate
+	w.mw = new(MuxWriter)
+	return w
+}
+
 func NewLinesRotateHandler(fp string, lines int) *RotateHandler {
 	w := &RotateHandler{
 		FilePath:  fp,
@@ -102,137 +367,860 @@ func NewSizeRotateHandler(fp string, size int) *RotateHandler {
 	return w
 }
 
-// inherit io.Writer
+// NewIntervalRotateHandler rotates fp every d, for periods such as 5 minutes
+// that daily or hourly rotation can't express.
+func NewIntervalRotateHandler(fp string, d time.Duration) *RotateHandler {
+	w := &RotateHandler{
+		FilePath:  fp,
+		Interval:  d,
+		Rotatable: true,
+	}
+	// use MuxWriter instead direct use os.File for lock write when rotate
+	w.mw = new(MuxWriter)
+	return w
+}
+
+// NewSizeRotateHandlerStr is like NewSizeRotateHandler but takes a size such
+// as "16M", with an optional K, M or G suffix (base 1024).
+func NewSizeRotateHandlerStr(fp string, size string) (*RotateHandler, error) {
+	n, err := parseSize(size)
+	if err != nil {
+		return nil, err
+	}
+	return NewSizeRotateHandler(fp, n), nil
+}
+
+// parseSize converts a human-readable size like "256M" to bytes.
+func parseSize(size string) (int, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	shift := uint(0)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		}
+		if shift > 0 {
+			s = s[:n-1]
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	if n > math.MaxInt>>shift {
+		return 0, fmt.Errorf("size %q overflows int", size)
+	}
+	return n << shift, nil
+}
+
+// inherit io.Writer. Each call's data is written as one piece, it is never
+// interleaved with another Write or split across files by a rotation, so
+// callers bypassing log.Logger should pass whole lines.
 func (w *RotateHandler) Write(data []byte) (int, error) {
-	if Debug {
+	if IsDebug() {
 		fmt.Println(string(data))
 	}
 	length := len(data)
-	w.doCheckRotate(length)
-	_, err := w.mw.Write(data)
-	return length, err
+	if w.RateLimit != nil && !w.RateLimit.wait() {
+		w.stats.drop()
+		return length, nil
+	}
+	data = w.transform(data)
+	n, err := w.write(payload{b: data}, 1)
+	if err != nil {
+		return n, err
+	}
+	w.subscribers.publish(data)
+	w.recent.add(data, w.RecentSize)
+	return length, nil
+}
+
+// WriteString is like Write but writes s without copying it to a []byte,
+// unless Transforms need one.
+func (w *RotateHandler) WriteString(s string) (int, error) {
+	if len(w.Transforms) > 0 {
+		return w.Write([]byte(s))
+	}
+	if IsDebug() {
+		fmt.Println(s)
+	}
+	if w.RateLimit != nil && !w.RateLimit.wait() {
+		w.stats.drop()
+		return len(s), nil
+	}
+	n, err := w.write(payload{s: s}, 1)
+	if err != nil {
+		return n, err
+	}
+	w.subscribers.publishString(s)
+	w.recent.addString(s, w.RecentSize)
+	return len(s), nil
+}
+
+// transform applies Transforms to data in order.
+func (w *RotateHandler) transform(data []byte) []byte {
+	for _, t := range w.Transforms {
+		data = t(data)
+	}
+	return data
+}
+
+// WriteLines writes a batch of lines, each ending in a newline, taking the
+// locks and checking for rotation once. The batch is never split across a
+// rotation, so it may push the file past its limits. RateLimit counts the
+// batch as one line.
+func (w *RotateHandler) WriteLines(lines [][]byte) (int, error) {
+	if len(lines) == 0 {
+		return 0, nil
+	}
+	if w.RateLimit != nil && !w.RateLimit.wait() {
+		w.stats.drop()
+		return 0, nil
+	}
+	if len(w.Transforms) > 0 {
+		transformed := make([][]byte, len(lines))
+		for i, line := range lines {
+			transformed[i] = w.transform(line)
+		}
+		lines = transformed
+	}
+	data := bytes.Join(lines, nil)
+	n, err := w.write(payload{b: data}, len(lines))
+	if err != nil {
+		return n, err
+	}
+	for _, line := range lines {
+		w.subscribers.publish(line)
+		w.recent.add(line, w.RecentSize)
+	}
+	return n, nil
 }
 
+// write writes data holding lines lines to the file as one piece.
+func (w *RotateHandler) write(data payload, lines int) (int, error) {
+	// hold startLock until the data is written, so a rotation triggered
+	// by another writer can't land between counting these lines and
+	// writing them
+	w.startLock.Lock()
+	defer w.startLock.Unlock()
+	if w.mw.logFile == nil {
+		// don't let a rotation check touch files before Init
+		return 0, ErrWriterNotInitialized
+	}
+	now := w.timeNow()
+	if now.Before(w.suspendUntil) {
+		if w.Fallback != nil {
+			return data.writeTo(w.Fallback)
+		}
+		w.stats.drop()
+		return 0, ErrWriteSuspended
+	}
+	w.checkMoved(now)
+	w.doCheckRotate(data.size(), lines)
+	n, err := data.writeTo(w.mw)
+	if err != nil && w.fileMoved() {
+		// the file was removed underneath us, write to a fresh one
+		if err = w.reopen(); err == nil {
+			n, err = data.writeTo(w.mw)
+		}
+	}
+	w.stats.wrote(n, lines)
+	if err != nil {
+		w.reportError(err)
+		if w.ErrorCooldown > 0 {
+			w.suspendUntil = now.Add(w.ErrorCooldown)
+		}
+		return n, err
+	}
+	return n, nil
+}
+
+// payload is the data of a write, held as bytes or, for WriteString, as a
+// string so it isn't copied.
+type payload struct {
+	b []byte
+	s string
+}
+
+func (p payload) size() int {
+	return len(p.b) + len(p.s)
+}
+
+func (p payload) writeTo(w io.Writer) (int, error) {
+	if p.b != nil {
+		return w.Write(p.b)
+	}
+	return io.WriteString(w, p.s)
+}
+
+// reportError prints err to the internal error writer, see
+// SetInternalErrorWriter, or queues it for OnError. Callers hold startLock,
+// or are inside a log.Logger writing to this handler, so OnError must not
+// run before they return.
+func (w *RotateHandler) reportError(err error) {
+	if w.OnError == nil {
+		w.reportErrorFor(w.FilePath, err)
+		return
+	}
+	w.queueAfterRotate(rotation{filePath: w.FilePath, err: err})
+}
+
+// reportErrorFor passes err to OnError, or prints it, right away. It is for
+// the goroutine running afterRotate, which passes the FilePath it works on.
+func (w *RotateHandler) reportErrorFor(filePath string, err error) {
+	if w.OnError != nil {
+		w.OnError(err)
+		return
+	}
+	internalErrorf("FileLogWriter(%q): %s\n", filePath, err)
+}
+
+// Init opens the log file, panicking on failure. See InitE.
 func (w *RotateHandler) Init() {
+	if err := w.InitE(); err != nil {
+		panic(err)
+	}
+}
+
+// InitE opens the log file and loads its current size and line count.
+func (w *RotateHandler) InitE() error {
+	loadDebug()
+	if err := w.openLogFile(); err != nil {
+		return err
+	}
+
+	w.startLock.Lock()
+	w.rotateOnOpen()
+	w.startLock.Unlock()
+
+	w.startSyncLoop()
+	return nil
+}
+
+// rotateOnOpen rotates a just opened file if it is already over its limits,
+// as a restarted process may find it, or if RotateOnStart asks for a fresh
+// file. Must hold startLock.
+func (w *RotateHandler) rotateOnOpen() {
+	if w.needRotate(w.timeNow()) || (w.RotateOnStart && w.curSize > w.headerSize) {
+		if err := w.doRotate(); err != nil {
+			w.reportError(err)
+		}
+	}
+}
+
+// openLogFile opens FilePath and loads its current size and line count.
+func (w *RotateHandler) openLogFile() error {
 	if len(w.FilePath) == 0 {
-		panic(errors.New("config must have filename"))
+		return errors.New("config must have filename")
 	}
 
 	fd, err := w.createLogFile()
 	if err != nil {
-		panic(err)
+		return err
+	}
+	// do everything that can fail before fd replaces the open file, so on
+	// error the handler keeps writing where it was
+	headerSize, err := w.writeHeader(fd)
+	var size, lines int
+	if err == nil {
+		size, lines, err = w.measureLogFile(fd)
+	}
+	if err == nil {
+		err = w.updateSymlink()
+	}
+	if err != nil {
+		fd.Close()
+		return err
 	}
 	w.mw.SetLogFile(fd)
-	if err = w.initLogFile(); err != nil {
-		panic(err)
+	w.headerSize = headerSize
+	w.initLogFile(size, lines)
+	return nil
+}
+
+// writeHeader writes HeaderFunc's header to fd if it is a new, empty file,
+// and returns its size.
+func (w *RotateHandler) writeHeader(fd *os.File) (int, error) {
+	if w.HeaderFunc == nil {
+		return 0, nil
+	}
+	fi, err := fd.Stat()
+	if err != nil || fi.Size() > 0 {
+		return 0, err
 	}
+	return fd.Write(w.HeaderFunc())
 }
 
-func (w *RotateHandler) doCheckRotate(size int) {
-	w.startLock.Lock()
-	defer w.startLock.Unlock()
-	if w.Rotatable && ((w.MaxLines > 0 && w.curLines >= w.MaxLines) ||
-		(w.MaxSize > 0 && w.curSize >= w.MaxSize) ||
-		(time.Now().Day() != w.openDate)) {
-		if err := w.DoRotate(); err != nil {
-			fmt.Fprintf(os.Stderr, "FileLogWriter(%q): %s\n", w.FilePath, err)
-			return
+// doCheckRotate rotates the file if it is over a limit, then counts size
+// bytes in lines lines against the current file. Must hold startLock.
+func (w *RotateHandler) doCheckRotate(size, lines int) {
+	if w.needRotate(w.timeNow()) {
+		if err := w.doRotate(); err != nil {
+			w.reportError(err)
 		}
 	}
-	w.curLines++
+	w.curLines += lines
 	w.curSize += size
 }
 
+// needRotate reports whether the current file is over a limit at now.
+func (w *RotateHandler) needRotate(now time.Time) bool {
+	return w.Rotatable && ((w.MaxLines > 0 && w.curLines >= w.MaxLines) ||
+		(w.MaxSize > 0 && w.curSize >= w.MaxSize) ||
+		(w.MaxHours > 0 && hourOf(now) != w.openHour) ||
+		(w.Interval > 0 && !now.Before(w.nextRotate)) ||
+		(w.Interval <= 0 && dateOf(now) != w.openDate))
+}
+
+// nextInterval returns the end of the interval the file opened at now
+// belongs to. It advances the previous end in whole steps of Interval, so
+// rotations don't drift with write times; after a gap of several intervals
+// the file is rotated once and the next end is the first one after now.
+func (w *RotateHandler) nextInterval(now time.Time) time.Time {
+	next := w.nextRotate
+	if next.IsZero() {
+		return now.Truncate(w.Interval).Add(w.Interval)
+	}
+	if next.After(now) {
+		// reopened before the interval ended
+		return next
+	}
+	steps := now.Sub(next)/w.Interval + 1
+	return next.Add(steps * w.Interval)
+}
+
 func (w *RotateHandler) createLogFile() (*os.File, error) {
 	os.MkdirAll(filepath.Dir(w.FilePath), 0755)
-	return os.OpenFile(w.FilePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if w.FileMode == 0 {
+		return os.OpenFile(w.FilePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	}
+	fd, err := os.OpenFile(w.FilePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, w.FileMode)
+	if err != nil {
+		return nil, err
+	}
+	// the mode given to OpenFile is masked by the umask
+	if err = fd.Chmod(w.FileMode); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	return fd, nil
 }
 
-func (w *RotateHandler) initLogFile() error {
-	fd := w.mw.logFile
+// measureLogFile returns the size and line count of fd, just opened on
+// FilePath.
+func (w *RotateHandler) measureLogFile(fd *os.File) (int, int, error) {
 	fInfo, err := fd.Stat()
 	if err != nil {
-		return fmt.Errorf("get stat: %s\n", err)
+		return 0, 0, fmt.Errorf("get stat: %s\n", err)
+	}
+	if fInfo.Size() == 0 {
+		return 0, 0, nil
+	}
+	f, err := os.Open(w.FilePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	lines, err := countLines(f, w.lineDelimiter())
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(fInfo.Size()), lines, nil
+}
+
+// initLogFile resets the counters and rotation times for a file just
+// opened holding size bytes in lines lines.
+func (w *RotateHandler) initLogFile(size, lines int) {
+	w.curSize, w.curLines = size, lines
+	now := w.timeNow()
+	w.openDate = dateOf(now)
+	w.openHour = hourOf(now)
+	if w.Interval > 0 {
+		w.nextRotate = w.nextInterval(now)
+	}
+}
+
+// lineDelimiter returns LineDelimiter or its default.
+func (w *RotateHandler) lineDelimiter() byte {
+	if w.LineDelimiter == 0 {
+		return '\n'
 	}
-	w.curSize = int(fInfo.Size())
-	w.openDate = time.Now().Day()
-	if fInfo.Size() > 0 {
-		content, err := ioutil.ReadFile(w.FilePath)
+	return w.LineDelimiter
+}
+
+// countLines counts delim-terminated lines in r, plus a trailing line
+// without a delimiter if there is one. r is read in fixed-size chunks so
+// memory use does not depend on the file size.
+func countLines(r io.Reader, delim byte) (int, error) {
+	buf := make([]byte, 32*1024)
+	n := 0
+	last := delim
+	for {
+		c, err := r.Read(buf)
+		if c > 0 {
+			n += bytes.Count(buf[:c], []byte{delim})
+			last = buf[c-1]
+		}
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return err
+			return 0, err
 		}
-		w.curLines = len(strings.Split(string(content), "\n"))
-	} else {
-		w.curLines = 0
 	}
-	return nil
+	if last != delim {
+		n++
+	}
+	return n, nil
+}
+
+// timeNow returns the handler's current time, see now, in UTC if
+// RotateUTC is set.
+func (w *RotateHandler) timeNow() time.Time {
+	t := time.Now()
+	if w.now != nil {
+		t = w.now()
+	}
+	if w.RotateUTC {
+		t = t.UTC()
+	}
+	return t
+}
+
+// dateOf returns t's calendar date as yyyymmdd, so that the same day number
+// in different months never compares equal.
+func dateOf(t time.Time) int {
+	y, m, d := t.Date()
+	return y*10000 + int(m)*100 + d
+}
+
+// hourOf returns t's hour bucket as yyyymmddhh.
+func hourOf(t time.Time) int {
+	return dateOf(t)*100 + t.Hour()
+}
+
+// suffixLayout is the time layout used in rotated file names, as precise as
+// the rotation period: the date, the hour when rotating hourly and the
+// second when rotating every Interval.
+func (w *RotateHandler) suffixLayout() string {
+	if w.Interval > 0 {
+		return "2006-01-02-15-04-05"
+	}
+	if w.MaxHours > 0 {
+		return "2006-01-02-15"
+	}
+	return "2006-01-02"
+}
+
+// maxAge is how long rotated files are kept before deleteOldLog removes them.
+func (w *RotateHandler) maxAge() time.Duration {
+	if w.MaxHours > 0 {
+		return time.Duration(w.MaxHours) * time.Hour
+	}
+	return time.Duration(w.MaxDays) * 24 * time.Hour
 }
 
 // DoRotate means it need to write file in new file.
-// new file name like xx.log.2013-01-01.2
+// new file name like xx.log.2013-01-01.001, xx.log.2013-01-01-15.001 when
+// rotating hourly, or xx.log.2013-01-01-15-04-05.001 every Interval. Fails
+// with ErrRotateExhausted when no number is free. It waits for a Write in
+// progress, so a line is never split across files. A call made while another
+// rotation is in progress waits for it and returns without rotating again.
 func (w *RotateHandler) DoRotate() error {
+	gen := atomic.LoadUint32(&w.rotations)
+	w.startLock.Lock()
+	defer w.startLock.Unlock()
+	return w.rotateSince(gen)
+}
+
+// rotateSince rotates unless a rotation completed since rotations was gen.
+// Must hold startLock.
+func (w *RotateHandler) rotateSince(gen uint32) error {
+	if atomic.LoadUint32(&w.rotations) != gen {
+		return nil
+	}
+	return w.doRotate()
+}
+
+// doRotate is DoRotate for callers holding startLock.
+func (w *RotateHandler) doRotate() error {
+	if w.mw.logFile == nil {
+		return ErrWriterNotInitialized
+	}
 	_, err := os.Lstat(w.FilePath)
 	if err == nil { // file exists
 		// Find the next available number
-		num := 1
-		fname := ""
-		for ; err == nil && num <= 999; num++ {
-			fname = w.FilePath + fmt.Sprintf(".%s.%03d", time.Now().Format("2006-01-02"), num)
-			_, err = os.Lstat(fname)
-		}
-		// return error if the last file checked still existed
-		if err == nil {
-			return fmt.Errorf("rotate: cannot find free log number to rename %s\n", w.FilePath)
+		fname, ok := w.nextRotatedName(w.timeNow())
+		if !ok {
+			return &RotateError{Op: "number", Path: w.FilePath, Err: ErrRotateExhausted}
 		}
 
 		// block Logger's io.Writer
 		w.mw.Lock()
-		defer w.mw.Unlock()
 
+		w.mw.flush()
 		fd := w.mw.logFile
+		oldInfo, _ := fd.Stat()
 		fd.Close()
 
 		// close fd before rename
 		// Rename the file to its newfound home
-		if err = os.Rename(w.FilePath, fname); err != nil {
-			return fmt.Errorf("Rotate: %s\n", err)
+		renamed := true
+		if err = rename(w.FilePath, fname); err != nil {
+			if !isCrossDevice(err) {
+				w.mw.Unlock()
+				return &RotateError{Op: "rename", Path: w.FilePath, Err: err}
+			}
+			// the archive is on another filesystem, copy it there instead
+			if err = copyTruncate(w.FilePath, fname); err != nil {
+				w.mw.Unlock()
+				return &RotateError{Op: "copy", Path: w.FilePath, Err: err}
+			}
+			renamed = false
 		}
 
 		// re-start logger
-		w.Init()
+		if err = w.openLogFile(); err != nil {
+			w.restoreLogFile(fname, renamed)
+			w.mw.Unlock()
+			return &RotateError{Op: "open", Path: w.FilePath, Err: err}
+		}
+		if oldInfo != nil {
+			// keep the owner downstream collectors expect
+			chownLike(w.mw.logFile, oldInfo)
+		}
+		w.mw.Unlock()
 
-		go w.deleteOldLog()
+		w.stats.rotated(w.timeNow())
+		atomic.AddUint32(&w.rotations, 1)
+		w.queueAfterRotate(rotation{fname: fname, filePath: w.FilePath})
 	}
 
 	return nil
 }
 
-func (w *RotateHandler) deleteOldLog() {
-	dir := filepath.Dir(w.FilePath)
-	filepath.Walk(dir, func(path string, info os.FileInfo, err error) (returnErr error) {
-		defer func() {
-			if r := recover(); r != nil {
-				returnErr = fmt.Errorf("Unable to delete old log '%s', error: %+v", path, r)
+// restoreLogFile moves the file doRotate renamed to fname back when the new
+// file could not be opened, and opens it again, so writes go on to it
+// instead of to the closed file. A copied file never moved and is only
+// reopened. If that fails too the next write tries to reopen FilePath.
+// Must hold startLock and mw.
+func (w *RotateHandler) restoreLogFile(fname string, renamed bool) {
+	if renamed && rename(fname, w.FilePath) != nil {
+		return
+	}
+	fd, err := w.createLogFile()
+	if err != nil {
+		return
+	}
+	size, lines, err := w.measureLogFile(fd)
+	if err != nil {
+		fd.Close()
+		return
+	}
+	w.mw.SetLogFile(fd)
+	w.curSize, w.curLines = size, lines
+}
+
+// maxSeq is the last rotation number tried for one date (or hour). Numbers
+// past 999 simply get wider in the default names.
+const maxSeq = 999999
+
+// ErrRotateExhausted is wrapped in the RotateError returned by DoRotate when
+// every rotation number for the current date (or hour) is taken.
+var ErrRotateExhausted = errors.New("cannot find free log number")
+
+// RotateError is returned by DoRotate, and passed to OnError from the
+// background steps after a rotation. Op is the step that failed: "number",
+// "rename", "copy", "open", "compress" or "cleanup".
+type RotateError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *RotateError) Error() string {
+	return fmt.Sprintf("rotate %s %s: %s", e.Op, e.Path, e.Err)
+}
+
+func (e *RotateError) Unwrap() error {
+	return e.Err
+}
+
+// nextRotatedName returns the first free rotated name at t. Numbering
+// resumes after the last number used for the same date (or hour), so the
+// directory is only scanned for numbers taken before this process started.
+func (w *RotateHandler) nextRotatedName(t time.Time) (string, bool) {
+	key := t.Format(w.suffixLayout())
+	if key != w.seqKey {
+		w.seqKey, w.lastSeq = key, 0
+	}
+	for num := w.lastSeq + 1; num <= maxSeq; num++ {
+		fname := w.rotatedName(w.FilePath, t, num)
+		if !w.rotatedExists(fname) {
+			w.lastSeq = num
+			return fname, true
+		}
+	}
+	return "", false
+}
+
+// rotatedExists reports whether fname, or one of its compressed forms,
+// exists.
+func (w *RotateHandler) rotatedExists(fname string) bool {
+	if _, err := os.Lstat(fname); err == nil {
+		return true
+	}
+	for _, ext := range w.archiveExts() {
+		if _, err := os.Lstat(fname + ext); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// rotation is a rotated file waiting for afterRotate, with the FilePath it
+// was rotated from, taken under startLock since SetFilePath may change it.
+// With err set it is instead an error reportError queued for OnError.
+type rotation struct {
+	fname    string
+	filePath string
+	err      error
+}
+
+// queueAfterRotate schedules afterRotate for r, starting the goroutine
+// running it unless it is already busy.
+func (w *RotateHandler) queueAfterRotate(r rotation) {
+	w.afterMu.Lock()
+	defer w.afterMu.Unlock()
+	w.afterQueue = append(w.afterQueue, r)
+	if w.afterIdle == nil {
+		w.afterIdle = make(chan struct{})
+		go w.runAfterRotate(w.afterIdle)
+	}
+}
+
+// runAfterRotate handles queued rotations until there are none left.
+func (w *RotateHandler) runAfterRotate(idle chan struct{}) {
+	for {
+		w.afterMu.Lock()
+		if len(w.afterQueue) == 0 {
+			w.afterIdle = nil
+			w.afterMu.Unlock()
+			close(idle)
+			return
+		}
+		r := w.afterQueue[0]
+		w.afterQueue = w.afterQueue[1:]
+		w.afterMu.Unlock()
+		w.afterRotate(r)
+	}
+}
+
+// waitAfterRotate waits until the steps after past rotations, and the
+// OnError calls queued before them, are done.
+func (w *RotateHandler) waitAfterRotate() {
+	w.afterMu.Lock()
+	idle := w.afterIdle
+	w.afterMu.Unlock()
+	if idle != nil {
+		<-idle
+	}
+}
+
+// afterRotate runs the post-rotation steps for r outside of any lock, so a
+// slow OnRotate callback never blocks writers.
+func (w *RotateHandler) afterRotate(r rotation) {
+	if r.err != nil {
+		w.reportErrorFor(r.filePath, r.err)
+		return
+	}
+	if w.OnRotate != nil {
+		w.OnRotate(r.fname)
+	}
+	if w.Compress {
+		w.compressOldLog(r)
+	}
+	// retention applies to all rotation modes, not only daily ones
+	if w.maxAge() > 0 || w.MaxBackups > 0 || w.MaxTotalSize > 0 {
+		w.deleteOldLog(r.filePath)
+	}
+}
+
+func (w *RotateHandler) compressOldLog(r rotation) {
+	fname := r.fname
+	c := w.Compressor
+	if c == nil {
+		c = Gzip
+	}
+	if _, err := os.Lstat(fname); os.IsNotExist(err) {
+		// retention already removed it while it waited in the queue
+		return
+	}
+	if err := compressFile(c, fname); err != nil {
+		w.reportErrorFor(r.filePath, &RotateError{Op: "compress", Path: fname, Err: err})
+	}
+}
+
+// deleteOldLog removes rotated files outside the retention policy. The age
+// limit (MaxDays, or MaxHours when rotating hourly), MaxBackups and
+// MaxTotalSize are applied independently: a rotated file is removed as soon
+// as any one excludes it. filePath is the file they were rotated from.
+func (w *RotateHandler) deleteOldLog(filePath string) {
+	files, err := w.rotatedFiles(filePath)
+	if err != nil {
+		w.reportErrorFor(filePath, &RotateError{Op: "cleanup", Path: filePath, Err: err})
+		return
+	}
+
+	if maxAge := w.maxAge(); maxAge > 0 {
+		cutoff := w.timeNow().Add(-maxAge)
+		kept := files[:0]
+		for _, f := range files {
+			if f.ModTime().Before(cutoff) {
+				os.Remove(f.path)
+				continue
 			}
-		}()
+			kept = append(kept, f)
+		}
+		files = kept
+	}
+
+	if w.MaxBackups > 0 && len(files) > w.MaxBackups {
+		for _, f := range files[:len(files)-w.MaxBackups] {
+			os.Remove(f.path)
+		}
+		files = files[len(files)-w.MaxBackups:]
+	}
 
-		if !info.IsDir() && info.ModTime().Unix() < (time.Now().Unix()-int64(60*60*24*w.MaxDays)) {
-			if strings.HasPrefix(filepath.Base(path), filepath.Base(w.FilePath)) {
-				os.Remove(path)
+	if w.MaxTotalSize > 0 {
+		var total int64
+		for _, f := range files {
+			total += f.Size()
+		}
+		for _, f := range files {
+			if total <= w.MaxTotalSize {
+				break
+			}
+			if err := os.Remove(f.path); err == nil {
+				total -= f.Size()
 			}
 		}
-		return returnErr
+	}
+}
+
+// rotatedFile is a file rotated out of FilePath.
+type rotatedFile struct {
+	path string
+	os.FileInfo
+}
+
+// rotatedFiles lists the files rotated out of filePath, oldest first.
+// Sequence numbers freed by cleanup are reused, so order by modification time
+// (the last write before rotation) and only fall back to the name.
+func (w *RotateHandler) rotatedFiles(filePath string) ([]rotatedFile, error) {
+	dir := filepath.Dir(w.rotatedName(filePath, w.timeNow(), 1))
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []rotatedFile
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		if !info.IsDir() && w.isRotated(filePath, path) {
+			files = append(files, rotatedFile{path: path, FileInfo: info})
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
 	})
+	return files, nil
+}
+
+// rotatedName returns the path filePath is rotated to at t with sequence seq.
+func (w *RotateHandler) rotatedName(filePath string, t time.Time, seq int) string {
+	if w.RotateUTC {
+		t = t.UTC()
+	}
+	if w.NameFunc != nil {
+		return w.NameFunc(filePath, t, seq)
+	}
+	return filePath + fmt.Sprintf(".%s.%03d", t.Format(w.suffixLayout()), seq)
+}
+
+// isRotated reports whether path is one of the files rotated out of
+// filePath.
+func (w *RotateHandler) isRotated(filePath, path string) bool {
+	if w.MatchFunc != nil {
+		return w.MatchFunc(path)
+	}
+	if filepath.Dir(path) != filepath.Dir(filePath) {
+		return false
+	}
+	return rotatedPattern(filepath.Base(filePath), w.archiveExts()).MatchString(filepath.Base(path))
 }
 
-// destroy file logger, close file writer.
+// rotatedPattern matches the default rotated names for base, such as
+// base.2013-01-01.001, base.2013-01-01-15.001.gz and
+// base.2013-01-01-15-04-05.001, but not the rotated files of another log
+// whose name merely starts with base. exts are the extensions compressed
+// ones may have.
+func rotatedPattern(base string, exts []string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(base) +
+		`\.\d{4}-\d{2}-\d{2}(-\d{2}(-\d{2}-\d{2})?)?\.\d{3,}` + extPattern(exts) + `$`)
+}
+
+// destroy file logger, close file writer. It waits for rotated files to be
+// compressed and cleaned up.
 func (w *RotateHandler) Close() {
-	w.mw.logFile.Close()
+	w.stopSignals()
+	w.stopSyncLoop()
+	w.mw.Close()
+	w.waitAfterRotate()
 }
 
 // flush file logger.
-// there are no buffering messages in file logger in memory.
-// flush file means sync file from disk.
+// write out messages buffered in memory, if any, then sync file to disk.
 func (w *RotateHandler) Flush() {
-	w.mw.logFile.Sync()
+	w.mw.syncMode(w.FlushMode)
+}
+
+// startSyncLoop starts syncing the file every SyncInterval, unless it is
+// already running.
+func (w *RotateHandler) startSyncLoop() {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	if w.SyncInterval <= 0 || w.syncStop != nil {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	w.syncStop, w.syncDone = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(w.SyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.Flush()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopSyncLoop stops the loop started by startSyncLoop and waits for it.
+func (w *RotateHandler) stopSyncLoop() {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	if w.syncStop == nil {
+		return
+	}
+	close(w.syncStop)
+	<-w.syncDone
+	w.syncStop, w.syncDone = nil, nil
 }
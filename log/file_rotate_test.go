@@ -0,0 +1,1216 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a RotateHandler clock tests move by hand.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newFakeClock(t time.Time) *fakeClock {
+	return &fakeClock{t: t}
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+func (c *fakeClock) add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// archiveNames returns the base names of h's rotated files.
+func archiveNames(t *testing.T, h *RotateHandler) []string {
+	t.Helper()
+	var names []string
+	for _, f := range archives(t, h) {
+		names = append(names, filepath.Base(f))
+	}
+	return names
+}
+
+func TestNewHourlyRotateHandler(t *testing.T) {
+	h := NewHourlyRotateHandler(filepath.Join(t.TempDir(), "test.log"), 3)
+	if !h.Rotatable || h.MaxHours != 3 {
+		t.Fatalf("Rotatable %v, MaxHours %d", h.Rotatable, h.MaxHours)
+	}
+	// rotated names carry the hour, and are kept for MaxHours
+	if got := h.suffixLayout(); got != "2006-01-02-15" {
+		t.Errorf("suffixLayout = %q", got)
+	}
+	if got := h.maxAge(); got != 3*time.Hour {
+		t.Errorf("maxAge = %v, want 3h", got)
+	}
+}
+
+func TestHourlyRotationFakeClock(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 3, 1, 10, 15, 0, 0, time.Local))
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxHours = 24
+		h.Rotatable = true
+		h.now = clock.now
+	})
+	h.Write([]byte("a\n"))
+	clock.add(30 * time.Minute) // 10:45, same hour
+	h.Write([]byte("b\n"))
+	if got := archiveNames(t, h); len(got) != 0 {
+		t.Fatalf("rotated within the hour: %v", got)
+	}
+
+	clock.add(30 * time.Minute) // 11:15
+	h.Write([]byte("c\n"))
+	want := []string{"test.log.2020-03-01-11.001"}
+	if got := archiveNames(t, h); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("archives = %v, want %v", got, want)
+	}
+	if got := readFile(t, filepath.Join(filepath.Dir(h.FilePath), want[0])); got != "a\nb\n" {
+		t.Errorf("archive = %q", got)
+	}
+	if got := readFile(t, h.FilePath); got != "c\n" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestIntervalRotationFakeClock(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 3, 1, 10, 1, 0, 0, time.Local))
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.Interval = 5 * time.Minute
+		h.Rotatable = true
+		h.now = clock.now
+	})
+	h.Write([]byte("a\n"))
+	clock.set(time.Date(2020, 3, 1, 10, 4, 59, 0, time.Local))
+	h.Write([]byte("b\n"))
+	if got := archiveNames(t, h); len(got) != 0 {
+		t.Fatalf("rotated within the interval: %v", got)
+	}
+
+	// the interval ends at 10:05 whenever the file was opened
+	clock.set(time.Date(2020, 3, 1, 10, 5, 0, 0, time.Local))
+	h.Write([]byte("c\n"))
+	// a gap of several intervals rotates once
+	clock.set(time.Date(2020, 3, 1, 10, 27, 0, 0, time.Local))
+	h.Write([]byte("d\n"))
+	clock.set(time.Date(2020, 3, 1, 10, 29, 0, 0, time.Local))
+	h.Write([]byte("e\n"))
+
+	want := "test.log.2020-03-01-10-05-00.001,test.log.2020-03-01-10-27-00.001"
+	if got := strings.Join(archiveNames(t, h), ","); got != want {
+		t.Fatalf("archives = %v, want %v", got, want)
+	}
+	if got := readFile(t, h.FilePath); got != "d\ne\n" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestRotatedNameUsesClock(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 3, 2, 1, 30, 0, 0, time.FixedZone("X", 2*3600)))
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.now = clock.now
+	})
+	h.Write([]byte("a\n"))
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	h.RotateUTC = true
+	h.Write([]byte("b\n"))
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	// 01:30 at UTC+2 is still the day before in UTC
+	want := "test.log.2020-03-01.001,test.log.2020-03-02.001"
+	if got := strings.Join(archiveNames(t, h), ","); got != want {
+		t.Errorf("archives = %v, want %v", got, want)
+	}
+}
+
+func TestDailyRotationAcrossMonths(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 1, 15, 12, 0, 0, 0, time.Local))
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.Rotatable = true
+		h.now = clock.now
+	})
+	h.Write([]byte("a\n"))
+	// the same day of the month, a month later
+	clock.set(time.Date(2020, 2, 15, 12, 0, 0, 0, time.Local))
+	h.Write([]byte("b\n"))
+	clock.add(time.Hour)
+	h.Write([]byte("c\n"))
+
+	want := []string{"test.log.2020-02-15.001"}
+	if got := archiveNames(t, h); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("archives = %v, want %v", got, want)
+	}
+	if got := readFile(t, filepath.Join(filepath.Dir(h.FilePath), want[0])); got != "a\n" {
+		t.Errorf("archive = %q", got)
+	}
+	if got := readFile(t, h.FilePath); got != "b\nc\n" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestNewSizeRotateHandlerStr(t *testing.T) {
+	tests := []struct {
+		size string
+		want int
+	}{
+		{"16M", 16 << 20},
+		{"256M", 256 << 20},
+		{"1G", 1 << 30},
+		{"1024", 1024},
+		{"4k", 4 << 10},
+	}
+	for _, tt := range tests {
+		h, err := NewSizeRotateHandlerStr("test.log", tt.size)
+		if err != nil {
+			t.Errorf("%q: %s", tt.size, err)
+			continue
+		}
+		if h.MaxSize != tt.want || !h.Rotatable {
+			t.Errorf("%q: MaxSize = %d, want %d", tt.size, h.MaxSize, tt.want)
+		}
+	}
+	for _, size := range []string{"12X", "", "M", "-1K"} {
+		if _, err := NewSizeRotateHandlerStr("test.log", size); err == nil {
+			t.Errorf("%q: no error", size)
+		}
+	}
+}
+
+func TestOnRotate(t *testing.T) {
+	var mu sync.Mutex
+	var rotated []string
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.OnRotate = func(path string) {
+			mu.Lock()
+			defer mu.Unlock()
+			rotated = append(rotated, path)
+		}
+	})
+	for i := 0; i < 3; i++ {
+		h.Write([]byte("line\n"))
+		if err := h.DoRotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.waitAfterRotate()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := archives(t, h)
+	if strings.Join(rotated, ",") != strings.Join(want, ",") {
+		t.Errorf("OnRotate got %v, want %v", rotated, want)
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		content string
+		want    int
+	}{
+		{"", 0},
+		{"a\n", 1},
+		{"a", 1},
+		{"a\nb\nc\n", 3},
+		{"a\nb\nc", 3},
+		{"\n\n", 2},
+	}
+	for _, tt := range tests {
+		got, err := countLines(strings.NewReader(tt.content), '\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("countLines(%q) = %d, want %d", tt.content, got, tt.want)
+		}
+
+		path := filepath.Join(t.TempDir(), "test.log")
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		h := NewDefaultHandler(path)
+		if err := h.InitE(); err != nil {
+			t.Fatal(err)
+		}
+		if h.curLines != tt.want {
+			t.Errorf("Init on %q: curLines = %d, want %d", tt.content, h.curLines, tt.want)
+		}
+		h.Close()
+	}
+}
+
+// BenchmarkInitLargeFile counts the lines of existing files of growing size;
+// the bytes allocated per op stay the same.
+func BenchmarkInitLargeFile(b *testing.B) {
+	line := []byte(strings.Repeat("x", 99) + "\n")
+	for _, mb := range []int{1, 16, 64} {
+		path := filepath.Join(b.TempDir(), "test.log")
+		f, err := os.Create(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < mb<<20/len(line); i++ {
+			f.Write(line)
+		}
+		f.Close()
+
+		b.Run(fmt.Sprintf("%dMB", mb), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				h := NewDefaultHandler(path)
+				if err := h.InitE(); err != nil {
+					b.Fatal(err)
+				}
+				h.Close()
+			}
+		})
+	}
+}
+
+func TestNameFunc(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 3, 1, 10, 0, 0, 0, time.Local))
+	dir := t.TempDir()
+	h := NewDefaultHandler(filepath.Join(dir, "app.log"))
+	h.now = clock.now
+	h.MaxBackups = 2
+	h.NameFunc = func(base string, t time.Time, seq int) string {
+		return strings.TrimSuffix(base, ".log") + fmt.Sprintf("-%s-%03d.log", t.Format("20060102"), seq)
+	}
+	h.MatchFunc = func(path string) bool {
+		matched, _ := filepath.Match(filepath.Join(dir, "app-*-*.log"), path)
+		return matched
+	}
+	if err := h.InitE(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	// not one of h's, cleanup must leave it
+	other := filepath.Join(dir, "other-20200301-001.log")
+	if err := ioutil.WriteFile(other, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		h.Write([]byte("line\n"))
+		if err := h.DoRotate(); err != nil {
+			t.Fatal(err)
+		}
+		h.waitAfterRotate()
+	}
+
+	got, err := filepath.Glob(filepath.Join(dir, "*-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "app-20200301-002.log"),
+		filepath.Join(dir, "app-20200301-003.log"),
+		other,
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+func TestSizeRotationMaxBackups(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxSize = 10
+		h.Rotatable = true
+		h.MaxBackups = 3
+	})
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(h, "line %02d\n", i)
+	}
+	h.waitAfterRotate()
+
+	var kept strings.Builder
+	got := archives(t, h)
+	for _, f := range got {
+		kept.WriteString(readFile(t, f))
+	}
+	want := "line 12\nline 13\nline 14\nline 15\nline 16\nline 17\n"
+	if len(got) != 3 || kept.String() != want {
+		t.Errorf("archives %v hold %q, want the newest three with %q", got, kept.String(), want)
+	}
+}
+
+func TestCleanupIgnoresOtherLogs(t *testing.T) {
+	dir := t.TempDir()
+	others := []string{
+		filepath.Join(dir, "app.log2.2020-01-01.001"),
+		filepath.Join(dir, "app.log.2020-01-01.001.bak"),
+		filepath.Join(dir, "app2.log.2020-01-01.001"),
+	}
+	for _, f := range others {
+		if err := ioutil.WriteFile(f, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-1000 * time.Hour)
+		os.Chtimes(f, old, old)
+	}
+	h := NewDailyRotateHandler(filepath.Join(dir, "app.log"), 1)
+	h.MaxBackups = 1
+	if err := h.InitE(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		h.Write([]byte("line\n"))
+		if err := h.DoRotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Close()
+
+	for _, f := range others {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("%s removed by app.log's cleanup", filepath.Base(f))
+		}
+	}
+	files, err := h.rotatedFiles(h.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !strings.HasSuffix(files[0].path, ".003") {
+		t.Errorf("app.log archives = %v, want the last one", files)
+	}
+}
+
+func TestConcurrentWritesAndRotations(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxLines = 10
+		h.Rotatable = true
+	})
+	const writers, perWriter = 8, 200
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				h.Write([]byte("line\n"))
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			h.DoRotate()
+		}
+	}()
+	wg.Wait()
+
+	total := countLinesIn(readFile(t, h.FilePath))
+	if n := h.CurrentLines(); n != total {
+		t.Errorf("CurrentLines = %d, the file holds %d", n, total)
+	}
+	for _, f := range archives(t, h) {
+		n := countLinesIn(readFile(t, f))
+		if n > 10 {
+			t.Errorf("%s holds %d lines, over MaxLines", f, n)
+		}
+		total += n
+	}
+	if total != writers*perWriter {
+		t.Errorf("%d lines in all files, want %d", total, writers*perWriter)
+	}
+	if st := h.Stats(); st.Lines != writers*perWriter {
+		t.Errorf("Stats().Lines = %d, want %d", st.Lines, writers*perWriter)
+	}
+}
+
+func TestRotateOversizedOnStart(t *testing.T) {
+	for _, h := range []*RotateHandler{
+		NewSizeRotateHandler(filepath.Join(t.TempDir(), "test.log"), 50),
+		NewLinesRotateHandler(filepath.Join(t.TempDir(), "test.log"), 3),
+	} {
+		seed := strings.Repeat("old line\n", 10)
+		if err := ioutil.WriteFile(h.FilePath, []byte(seed), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.InitE(); err != nil {
+			t.Fatal(err)
+		}
+		h.Write([]byte("new\n"))
+		h.Close()
+
+		got := archives(t, h)
+		if len(got) != 1 || readFile(t, got[0]) != seed {
+			t.Errorf("archives = %v, want the seeded file", got)
+		}
+		if s := readFile(t, h.FilePath); s != "new\n" {
+			t.Errorf("file = %q, want only the new line", s)
+		}
+	}
+}
+
+func TestRapidRotationsNumbering(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 3, 1, 10, 0, 0, 0, time.Local))
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.now = clock.now
+	})
+	const n = 200
+	for i := 0; i < n; i++ {
+		h.Write([]byte("line\n"))
+		if err := h.DoRotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := archiveNames(t, h)
+	if len(got) != n || got[0] != "test.log.2020-03-01.001" || got[n-1] != "test.log.2020-03-01.200" {
+		t.Fatalf("%d archives from %v to %v", len(got), got[0], got[len(got)-1])
+	}
+	if h.lastSeq != n {
+		t.Errorf("lastSeq = %d, want %d", h.lastSeq, n)
+	}
+
+	// a new process scans once and goes on after the taken numbers
+	h.Close()
+	h2 := NewDefaultHandler(h.FilePath)
+	h2.now = clock.now
+	if err := h2.InitE(); err != nil {
+		t.Fatal(err)
+	}
+	defer h2.Close()
+	h2.Write([]byte("line\n"))
+	if err := h2.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	if h2.lastSeq != n+1 {
+		t.Errorf("after restart lastSeq = %d, want %d", h2.lastSeq, n+1)
+	}
+}
+
+func BenchmarkDoRotate(b *testing.B) {
+	h := NewDefaultHandler(filepath.Join(b.TempDir(), "test.log"))
+	if err := h.InitE(); err != nil {
+		b.Fatal(err)
+	}
+	defer h.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Write([]byte("line\n"))
+		if err := h.DoRotate(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRotateNumbersWiden(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 3, 1, 10, 0, 0, 0, time.Local))
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.now = clock.now
+	})
+	for i := 1; i <= 999; i++ {
+		if err := ioutil.WriteFile(h.rotatedName(h.FilePath, clock.now(), i), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Write([]byte("line\n"))
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	want := h.FilePath + ".2020-03-01.1000"
+	if got := readFile(t, want); got != "line\n" {
+		t.Errorf("%s = %q", want, got)
+	}
+	if !h.isRotated(h.FilePath, want) {
+		t.Error("cleanup does not match widened numbers")
+	}
+}
+
+func TestRotateExhausted(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 3, 1, 10, 0, 0, 0, time.Local))
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.now = clock.now
+	})
+	// skip to the last number and take it
+	h.seqKey, h.lastSeq = "2020-03-01", maxSeq-1
+	if err := ioutil.WriteFile(h.rotatedName(h.FilePath, clock.now(), maxSeq), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("line\n"))
+	err := h.DoRotate()
+	var re *RotateError
+	if !errors.Is(err, ErrRotateExhausted) || !errors.As(err, &re) || re.Op != "number" {
+		t.Fatalf("DoRotate = %v, want ErrRotateExhausted", err)
+	}
+	if got := readFile(t, h.FilePath); got != "line\n" {
+		t.Errorf("file = %q, want it kept", got)
+	}
+}
+
+func TestWriteBeforeInit(t *testing.T) {
+	h := NewSizeRotateHandler(filepath.Join(t.TempDir(), "test.log"), 1)
+	if _, err := h.Write([]byte("line\n")); err != ErrWriterNotInitialized {
+		t.Errorf("Write = %v, want ErrWriterNotInitialized", err)
+	}
+	if _, err := h.WriteString("line\n"); err != ErrWriterNotInitialized {
+		t.Errorf("WriteString = %v, want ErrWriterNotInitialized", err)
+	}
+	if err := h.DoRotate(); err != ErrWriterNotInitialized {
+		t.Errorf("DoRotate = %v, want ErrWriterNotInitialized", err)
+	}
+	// nothing to flush or close, and no panic
+	h.Flush()
+	h.Close()
+
+	mw := new(MuxWriter)
+	if _, err := mw.Write([]byte("x")); err != ErrWriterNotInitialized {
+		t.Errorf("MuxWriter.Write = %v", err)
+	}
+	if err := mw.Flush(); err != ErrWriterNotInitialized {
+		t.Errorf("MuxWriter.Flush = %v", err)
+	}
+	if err := mw.Close(); err != ErrWriterNotInitialized {
+		t.Errorf("MuxWriter.Close = %v", err)
+	}
+	mw.SetLogFile(nil)
+	if _, err := os.Stat(h.FilePath); !os.IsNotExist(err) {
+		t.Error("file created without Init")
+	}
+}
+
+func TestLargeWritesNotTorn(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxSize = 32 << 10
+		h.Rotatable = true
+	})
+	const writers, perWriter, size = 8, 50, 8 << 10
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(c byte) {
+			defer wg.Done()
+			line := append(bytes.Repeat([]byte{c}, size-1), '\n')
+			for j := 0; j < perWriter; j++ {
+				h.Write(line)
+			}
+		}(byte('a' + i))
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			h.DoRotate()
+		}
+	}()
+	wg.Wait()
+
+	lines := 0
+	for _, f := range append(archives(t, h), h.FilePath) {
+		content := readFile(t, f)
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			t.Errorf("%s ends in a partial line", f)
+		}
+		for _, line := range strings.SplitAfter(content, "\n") {
+			if line == "" {
+				continue
+			}
+			lines++
+			if len(line) != size || strings.Trim(line[:size-1], line[:1]) != "" {
+				t.Fatalf("%s has a torn line of %d bytes", f, len(line))
+			}
+		}
+	}
+	if lines != writers*perWriter {
+		t.Errorf("%d whole lines, want %d", lines, writers*perWriter)
+	}
+}
+
+func TestMaxTotalSize(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxTotalSize = 250
+		h.MaxBackups = 10
+	})
+	line := []byte(strings.Repeat("x", 99) + "\n")
+	for i := 0; i < 5; i++ {
+		h.Write(line)
+		if err := h.DoRotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.waitAfterRotate()
+
+	var total int64
+	got := archives(t, h)
+	for _, f := range got {
+		fi, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += fi.Size()
+	}
+	if total > 250 || len(got) != 2 {
+		t.Errorf("%d archives of %d bytes, want the 2 that fit in 250", len(got), total)
+	}
+	// the newest are kept
+	if len(got) == 2 && (!strings.HasSuffix(got[0], ".004") || !strings.HasSuffix(got[1], ".005")) {
+		t.Errorf("kept %v", got)
+	}
+}
+
+func TestNextInterval(t *testing.T) {
+	at := func(min, sec int) time.Time {
+		return time.Date(2020, 3, 1, 10, min, sec, 0, time.UTC)
+	}
+	w := &RotateHandler{Interval: 5 * time.Minute}
+	tests := []struct {
+		next, now, want time.Time
+	}{
+		{time.Time{}, at(3, 0), at(5, 0)}, // first open aligns to the interval
+		{at(5, 0), at(4, 59), at(5, 0)},   // reopened within the interval
+		{at(5, 0), at(5, 0), at(10, 0)},   // on time
+		{at(5, 0), at(7, 30), at(10, 0)},  // late, no drift
+		{at(5, 0), at(27, 0), at(30, 0)},  // asleep for several intervals
+		{at(5, 0), at(30, 0), at(35, 0)},  // asleep, exactly on a boundary
+	}
+	for _, tt := range tests {
+		w.nextRotate = tt.next
+		if got := w.nextInterval(tt.now); !got.Equal(tt.want) {
+			t.Errorf("next %v, now %v: got %v, want %v",
+				tt.next.Format("15:04:05"), tt.now.Format("15:04:05"), got.Format("15:04:05"), tt.want.Format("15:04:05"))
+		}
+	}
+}
+
+// breakFile swaps h's file for a read-only handle on it, so writes fail.
+func breakFile(t *testing.T, h *RotateHandler) {
+	t.Helper()
+	fd, err := os.Open(h.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.mw.Lock()
+	h.mw.SetLogFile(fd)
+	h.mw.Unlock()
+}
+
+func TestOnError(t *testing.T) {
+	var errs []error
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.OnError = func(err error) { errs = append(errs, err) }
+	})
+	breakFile(t, h)
+	if _, err := h.Write([]byte("line\n")); err == nil {
+		t.Fatal("Write to a read-only file succeeded")
+	}
+	h.waitAfterRotate()
+	if len(errs) != 1 {
+		t.Fatalf("OnError got %v for a failed write, want one error", errs)
+	}
+
+	// a rotation that can't rename the file
+	h.Reopen()
+	h.MaxLines = 1
+	h.Rotatable = true
+	h.NameFunc = func(base string, _ time.Time, seq int) string {
+		return filepath.Join(base+".missing", strconv.Itoa(seq))
+	}
+	h.MatchFunc = func(string) bool { return false }
+	h.Write([]byte("a\n"))
+	h.Write([]byte("b\n"))
+	h.waitAfterRotate()
+	var re *RotateError
+	if len(errs) != 2 || !errors.As(errs[1], &re) || re.Op != "rename" {
+		t.Fatalf("OnError got %v, want a rename error", errs)
+	}
+	if got := readFile(t, h.FilePath); got != "a\nb\n" {
+		t.Errorf("file = %q, want writes to go on", got)
+	}
+}
+
+func TestOnErrorLogsToSameLogger(t *testing.T) {
+	var l *Vlogger
+	calls := 0
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxLines = 1
+		h.Rotatable = true
+		h.NameFunc = func(base string, _ time.Time, seq int) string {
+			return filepath.Join(base+".missing", strconv.Itoa(seq))
+		}
+		h.MatchFunc = func(string) bool { return false }
+		h.OnError = func(err error) {
+			// the warning fails to rotate too, log only the first error
+			if calls++; calls == 1 {
+				l.Warnf("rotation failed: %s", err)
+			}
+		}
+	})
+	l = NewWithHandler("app", h)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Info("a")
+		l.Info("b")
+		h.waitAfterRotate()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging from OnError deadlocked")
+	}
+	if got := readFile(t, h.FilePath); !strings.Contains(got, "WARN: rotation failed: rotate rename") {
+		t.Errorf("file = %q, want the warning", got)
+	}
+	if calls != 2 {
+		t.Errorf("OnError called %d times, want 2", calls)
+	}
+}
+
+func TestErrorCooldown(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 3, 1, 10, 0, 0, 0, time.Local))
+	var fallback bytes.Buffer
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.now = clock.now
+		h.ErrorCooldown = time.Minute
+		h.Fallback = &fallback
+		h.OnError = func(error) {}
+	})
+	breakFile(t, h)
+	if _, err := h.Write([]byte("failed\n")); err == nil {
+		t.Fatal("Write to a read-only file succeeded")
+	}
+	h.Reopen() // the disk has room again, but the cooldown runs on
+
+	clock.add(30 * time.Second)
+	if _, err := h.Write([]byte("spilled\n")); err != nil {
+		t.Errorf("Write during the cooldown = %v", err)
+	}
+	h.Fallback = nil
+	if _, err := h.Write([]byte("dropped\n")); err != ErrWriteSuspended {
+		t.Errorf("Write during the cooldown without Fallback = %v, want ErrWriteSuspended", err)
+	}
+
+	clock.add(31 * time.Second)
+	if _, err := h.Write([]byte("recovered\n")); err != nil {
+		t.Errorf("Write after the cooldown = %v", err)
+	}
+	if got := readFile(t, h.FilePath); got != "recovered\n" {
+		t.Errorf("file = %q", got)
+	}
+	if fallback.String() != "spilled\n" {
+		t.Errorf("fallback = %q", fallback.String())
+	}
+	if d := h.Stats().Dropped; d != 1 {
+		t.Errorf("Stats().Dropped = %d, want 1", d)
+	}
+}
+
+func TestWriteLinesNotSplit(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxLines = 3
+		h.Rotatable = true
+	})
+	h.Write([]byte("a\n"))
+	h.Write([]byte("b\n"))
+	// the batch goes in whole, past MaxLines, and the next write rotates
+	h.WriteLines([][]byte{[]byte("c\n"), []byte("d\n"), []byte("e\n")})
+	if got := h.CurrentLines(); got != 5 {
+		t.Errorf("CurrentLines() = %d, want 5", got)
+	}
+	h.Write([]byte("f\n"))
+	h.waitAfterRotate()
+
+	got := archives(t, h)
+	if len(got) != 1 {
+		t.Fatalf("archives = %v, want one", got)
+	}
+	if s := readFile(t, got[0]); s != "a\nb\nc\nd\ne\n" {
+		t.Errorf("archive = %q", s)
+	}
+	if s := readFile(t, h.FilePath); s != "f\n" {
+		t.Errorf("file = %q", s)
+	}
+}
+
+func BenchmarkWriteLines(b *testing.B) {
+	batch := make([][]byte, 10)
+	for i := range batch {
+		batch[i] = []byte("request served in 12ms\n")
+	}
+	newHandler := func(b *testing.B) *RotateHandler {
+		h := NewDefaultHandler(filepath.Join(b.TempDir(), "bench.log"))
+		if err := h.InitE(); err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { h.Close() })
+		return h
+	}
+	b.Run("PerLine", func(b *testing.B) {
+		h := newHandler(b)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, line := range batch {
+				h.Write(line)
+			}
+		}
+	})
+	b.Run("Batch", func(b *testing.B) {
+		h := newHandler(b)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h.WriteLines(batch)
+		}
+	})
+}
+
+func TestLineDelimiter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	if err := ioutil.WriteFile(path, []byte("a;b;c;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := NewDefaultHandler(path)
+	h.LineDelimiter = ';'
+	h.MaxLines = 4
+	h.Rotatable = true
+	if err := h.InitE(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if h.curLines != 3 {
+		t.Fatalf("curLines = %d, want 3", h.curLines)
+	}
+	h.Write([]byte("d;"))
+	h.Write([]byte("e;"))
+	h.waitAfterRotate()
+
+	got := archives(t, h)
+	if len(got) != 1 {
+		t.Fatalf("archives = %v, want one", got)
+	}
+	if s := readFile(t, got[0]); s != "a;b;c;d;" {
+		t.Errorf("archive = %q", s)
+	}
+	if s := readFile(t, h.FilePath); s != "e;" {
+		t.Errorf("file = %q", s)
+	}
+}
+
+type failCompressor struct{}
+
+func (failCompressor) Ext() string { return ".fail" }
+
+func (failCompressor) Compress(dst, src string) error {
+	return errors.New("compressor failed")
+}
+
+func TestRotateErrorOps(t *testing.T) {
+	// rotateOp returns the Op of the RotateError from DoRotate or, for the
+	// background steps, passed to OnError.
+	rotateOp := func(t *testing.T, setup func(h *RotateHandler)) string {
+		var mu sync.Mutex
+		var errs []error
+		h := newTestHandler(t, func(h *RotateHandler) {
+			h.OnError = func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
+			}
+			setup(h)
+		})
+		h.Write([]byte("line\n"))
+		err := h.DoRotate()
+		h.waitAfterRotate()
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil && len(errs) > 0 {
+			err = errs[0]
+		}
+		var re *RotateError
+		if !errors.As(err, &re) {
+			t.Fatalf("got %v, want a RotateError", err)
+		}
+		return re.Op
+	}
+
+	clock := newFakeClock(time.Date(2020, 3, 1, 10, 0, 0, 0, time.Local))
+	tests := []struct {
+		name  string
+		setup func(h *RotateHandler)
+	}{
+		{"number", func(h *RotateHandler) {
+			h.now = clock.now
+			h.seqKey, h.lastSeq = "2020-03-01", maxSeq
+		}},
+		{"rename", func(h *RotateHandler) {
+			h.NameFunc = func(base string, _ time.Time, seq int) string {
+				return filepath.Join(base+".missing", strconv.Itoa(seq))
+			}
+		}},
+		{"compress", func(h *RotateHandler) {
+			h.Compress = true
+			h.Compressor = failCompressor{}
+		}},
+		{"cleanup", func(h *RotateHandler) {
+			// number 1 is in a missing directory, which cleanup lists
+			h.now = clock.now
+			h.seqKey, h.lastSeq = "2020-03-01", 1
+			h.MaxBackups = 1
+			h.NameFunc = func(base string, _ time.Time, seq int) string {
+				if seq == 1 {
+					return filepath.Join(base+".missing", "1")
+				}
+				return base + "." + strconv.Itoa(seq)
+			}
+			h.MatchFunc = func(string) bool { return true }
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if op := rotateOp(t, tt.setup); op != tt.name {
+				t.Errorf("Op = %q, want %q", op, tt.name)
+			}
+		})
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	email := regexp.MustCompile(`[a-z]+@[a-z.]+`)
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.Transforms = []func([]byte) []byte{
+			func(b []byte) []byte { return email.ReplaceAll(b, []byte("<email>")) },
+			// runs second, so it sees the redacted line
+			func(b []byte) []byte { return bytes.Replace(b, []byte("<email>"), []byte("[redacted]"), -1) },
+		}
+	})
+	line := "login by bob@example.com\n"
+	if n, err := h.Write([]byte(line)); n != len(line) || err != nil {
+		t.Errorf("Write = %d, %v, want %d bytes", n, err, len(line))
+	}
+	if n, _ := h.WriteString(line); n != len(line) {
+		t.Errorf("WriteString = %d, want %d bytes", n, len(line))
+	}
+	want := "login by [redacted]\n"
+	if got := readFile(t, h.FilePath); got != want+want {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestSuffixPrecision(t *testing.T) {
+	at := time.Date(2020, 3, 1, 10, 4, 5, 0, time.Local)
+	tests := []struct {
+		name  string
+		setup func(h *RotateHandler)
+		want  string
+	}{
+		{"daily", func(h *RotateHandler) {}, "test.log.2020-03-01.001"},
+		{"hourly", func(h *RotateHandler) { h.MaxHours = 24 }, "test.log.2020-03-01-10.001"},
+		{"interval", func(h *RotateHandler) { h.Interval = time.Minute }, "test.log.2020-03-01-10-04-05.001"},
+	}
+	for _, tt := range tests {
+		h := NewDefaultHandler("test.log")
+		tt.setup(h)
+		if got := h.rotatedName(h.FilePath, at, 1); got != tt.want {
+			t.Errorf("%s: rotatedName = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRotateUTCNearLocalMidnight(t *testing.T) {
+	zone := time.FixedZone("UTC+2", 2*3600)
+	for _, utc := range []bool{false, true} {
+		// 23:30 local is 21:30 UTC
+		clock := newFakeClock(time.Date(2020, 3, 1, 23, 30, 0, 0, zone))
+		h := newTestHandler(t, func(h *RotateHandler) {
+			h.Rotatable = true
+			h.RotateUTC = utc
+			h.now = clock.now
+		})
+		h.Write([]byte("a\n"))
+		clock.add(time.Hour) // past local midnight only
+		h.Write([]byte("b\n"))
+		clock.add(2 * time.Hour) // past UTC midnight too
+		h.Write([]byte("c\n"))
+
+		// archives are named for the day they were rotated on
+		want := "test.log.2020-03-02.001"
+		file := "b\nc\n"
+		if utc {
+			file = "c\n"
+		}
+		if got := strings.Join(archiveNames(t, h), ","); got != want {
+			t.Errorf("RotateUTC=%v: archives = %v, want %v", utc, got, want)
+		}
+		if got := readFile(t, h.FilePath); got != file {
+			t.Errorf("RotateUTC=%v: file = %q, want %q", utc, got, file)
+		}
+	}
+}
+
+func TestHeaderFunc(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.HeaderFunc = func() []byte { return []byte("time,level,msg\n") }
+	})
+	h.Write([]byte("a\n"))
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("b\n"))
+	h.Close()
+
+	got := archives(t, h)
+	if len(got) != 1 {
+		t.Fatalf("archives = %v, want one", got)
+	}
+	if s := readFile(t, got[0]); s != "time,level,msg\na\n" {
+		t.Errorf("archive = %q", s)
+	}
+	if s := readFile(t, h.FilePath); s != "time,level,msg\nb\n" {
+		t.Errorf("file = %q", s)
+	}
+
+	// appending to the existing file doesn't repeat the header
+	again := NewDefaultHandler(h.FilePath)
+	again.HeaderFunc = h.HeaderFunc
+	if err := again.InitE(); err != nil {
+		t.Fatal(err)
+	}
+	again.Write([]byte("c\n"))
+	again.Close()
+	if s := readFile(t, h.FilePath); s != "time,level,msg\nb\nc\n" {
+		t.Errorf("file after append = %q", s)
+	}
+}
+
+func TestRotateOnStart(t *testing.T) {
+	dir := t.TempDir()
+	for _, content := range []string{"", "from the last run\n"} {
+		path := filepath.Join(dir, "test.log")
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		h := NewDefaultHandler(path)
+		h.RotateOnStart = true
+		if err := h.InitE(); err != nil {
+			t.Fatal(err)
+		}
+		h.Close()
+
+		got := archives(t, h)
+		if content == "" {
+			if len(got) != 0 {
+				t.Errorf("empty file archived: %v", got)
+			}
+			continue
+		}
+		if len(got) != 1 || readFile(t, got[0]) != content {
+			t.Fatalf("archives = %v, want the old file", got)
+		}
+		if s := readFile(t, path); s != "" {
+			t.Errorf("file = %q, want a fresh one", s)
+		}
+	}
+}
+
+func TestWriteStringMatchesWrite(t *testing.T) {
+	lines := []string{"one\n", "two\n", "three\n"}
+	bytesH := newTestHandler(t, nil)
+	stringH := newTestHandler(t, nil)
+	for _, line := range lines {
+		n, err := bytesH.Write([]byte(line))
+		sn, serr := stringH.WriteString(line)
+		if n != sn || err != serr {
+			t.Errorf("%q: Write = %d, %v; WriteString = %d, %v", line, n, err, sn, serr)
+		}
+	}
+	if b, s := readFile(t, bytesH.FilePath), readFile(t, stringH.FilePath); b != s {
+		t.Errorf("Write wrote %q, WriteString %q", b, s)
+	}
+	if b, s := bytesH.Stats(), stringH.Stats(); b != s {
+		t.Errorf("Stats: Write %+v, WriteString %+v", b, s)
+	}
+	if bytesH.CurrentSize() != stringH.CurrentSize() || bytesH.CurrentLines() != stringH.CurrentLines() {
+		t.Errorf("current file: Write %d bytes, %d lines; WriteString %d bytes, %d lines",
+			bytesH.CurrentSize(), bytesH.CurrentLines(), stringH.CurrentSize(), stringH.CurrentLines())
+	}
+}
+
+func BenchmarkWriteString(b *testing.B) {
+	line := "a typical log line of some length\n"
+	newHandler := func(b *testing.B) *RotateHandler {
+		h := NewDefaultHandler(filepath.Join(b.TempDir(), "bench.log"))
+		if err := h.InitE(); err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { h.Close() })
+		return h
+	}
+	b.Run("Write", func(b *testing.B) {
+		h := newHandler(b)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h.Write([]byte(line))
+		}
+	})
+	b.Run("WriteString", func(b *testing.B) {
+		h := newHandler(b)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h.WriteString(line)
+		}
+	})
+}
+
+func TestConcurrentDoRotate(t *testing.T) {
+	h := newTestHandler(t, nil)
+	h.Write([]byte("line\n"))
+
+	// callers arriving during a rotation wait for it, then return
+	h.startLock.Lock()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- h.DoRotate()
+		}()
+	}
+	time.Sleep(50 * time.Millisecond) // let them queue on startLock
+	err := h.doRotate()
+	h.startLock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("DoRotate = %v", err)
+		}
+	}
+	h.waitAfterRotate()
+
+	if got := archives(t, h); len(got) != 1 {
+		t.Errorf("archives = %v, want exactly one", got)
+	}
+	if s := h.Stats(); s.Rotations != 1 {
+		t.Errorf("Stats().Rotations = %d, want 1", s.Rotations)
+	}
+}
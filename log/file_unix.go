@@ -0,0 +1,32 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// chownLike gives fd the owner and group of the file described by fi. It is
+// best effort: without privileges only the group may be changed.
+func chownLike(fd *os.File, fi os.FileInfo) {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		fd.Chown(int(st.Uid), int(st.Gid))
+	}
+}
+
+// isCrossDevice reports whether err is a rename failing because source and
+// destination are on different filesystems.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
+
+// writable reports an error unless this process may write to path.
+func writable(path string) error {
+	if err := syscall.Access(path, 2); err != nil { // W_OK
+		return &os.PathError{Op: "validate", Path: path, Err: err}
+	}
+	return nil
+}
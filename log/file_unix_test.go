@@ -0,0 +1,139 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFileMode(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.FileMode = 0600
+	})
+	checkMode := func() {
+		t.Helper()
+		fi, err := os.Stat(h.FilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0600 {
+			t.Errorf("mode = %v, want 0600", fi.Mode().Perm())
+		}
+	}
+	checkMode()
+	h.Write([]byte("line\n"))
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	checkMode()
+}
+
+func TestRotateKeepsOwner(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the owner needs root")
+	}
+	h := newTestHandler(t, nil)
+	if err := os.Chown(h.FilePath, 1234, 5678); err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("line\n"))
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(h.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := fi.Sys().(*syscall.Stat_t)
+	if st.Uid != 1234 || st.Gid != 5678 {
+		t.Errorf("new file owned by %d:%d, want 1234:5678", st.Uid, st.Gid)
+	}
+}
+
+func TestGetLoggerOtherNameWhileOpening(t *testing.T) {
+	dir := useLogDir(t)
+	// opening a FIFO for writing blocks until it has a reader
+	fifo := filepath.Join(dir, "slow.log")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skip(err)
+	}
+	slow := make(chan *Vlogger)
+	go func() {
+		slow <- GetLogger("slow", RotateModeNoRotate)
+	}()
+	for {
+		bose.mu.Lock()
+		_, opening := bose.creating["slow"]
+		bose.mu.Unlock()
+		if opening {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	fast := make(chan *Vlogger)
+	go func() {
+		fast <- GetLogger("fast", RotateModeNoRotate)
+	}()
+	select {
+	case l := <-fast:
+		if l == nil || l.Name != "fast" {
+			t.Errorf("GetLogger(fast) = %v", l)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("GetLogger(fast) waited for another name being opened")
+	}
+
+	// release the writer
+	r, err := os.Open(fifo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if l := <-slow; l == nil {
+		t.Error("GetLogger(slow) = nil")
+	}
+}
+
+func TestLoadConfigOpensWithoutLock(t *testing.T) {
+	dir := useLogDir(t)
+	fifo := filepath.Join(dir, "slow.log")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skip(err)
+	}
+	config := writeConfig(t, `[{"name": "slow", "mode": "none"}]`)
+	loaded := make(chan error, 1)
+	go func() {
+		loaded <- LoadConfig(config)
+	}()
+	// give LoadConfig time to block opening the FIFO
+	time.Sleep(50 * time.Millisecond)
+
+	fast := make(chan *Vlogger)
+	go func() {
+		fast <- GetLogger("fast", RotateModeNoRotate)
+	}()
+	select {
+	case <-fast:
+	case <-time.After(5 * time.Second):
+		t.Error("GetLogger waited for LoadConfig opening another file")
+	}
+
+	// release the writer
+	r, err := os.Open(fifo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := <-loaded; err != nil {
+		t.Fatal(err)
+	}
+	if !HasLogger("slow") {
+		t.Error("slow not registered")
+	}
+}
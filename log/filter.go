@@ -0,0 +1,33 @@
+package log
+
+import "regexp"
+
+// filter holds the patterns set by SetFilter.
+type filter struct {
+	allow, deny *regexp.Regexp
+}
+
+// SetFilter drops messages matching deny and, if allow is set, messages not
+// matching allow; deny wins when both match. Patterns are matched against
+// the message text, without level or fields. Nil patterns are ignored, so
+// SetFilter(nil, nil) removes the filter. It is safe to call while other
+// goroutines are logging.
+func (l *Vlogger) SetFilter(allow, deny *regexp.Regexp) {
+	if allow == nil && deny == nil {
+		l.filter.Store((*filter)(nil))
+		return
+	}
+	l.filter.Store(&filter{allow: allow, deny: deny})
+}
+
+// filtered reports whether msg is dropped by the filter.
+func (l *Vlogger) filtered(msg string) bool {
+	f, _ := l.filter.Load().(*filter)
+	if f == nil {
+		return false
+	}
+	if f.deny != nil && f.deny.MatchString(msg) {
+		return true
+	}
+	return f.allow != nil && !f.allow.MatchString(msg)
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestSetFilter(t *testing.T) {
+	msgs := []string{"GET /health", "GET /users", "POST /users", "noise from dep"}
+	tests := []struct {
+		name        string
+		allow, deny string
+		want        string
+	}{
+		{"none", "", "", "GET /health,GET /users,POST /users,noise from dep,"},
+		{"allow", "/users", "", "GET /users,POST /users,"},
+		{"deny", "", "health|noise", "GET /users,POST /users,"},
+		// deny wins over allow
+		{"both", "^GET", "health", "GET /users,"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		l := NewWriter("app", &buf)
+		l.SetFlags(0)
+		var allow, deny *regexp.Regexp
+		if tt.allow != "" {
+			allow = regexp.MustCompile(tt.allow)
+		}
+		if tt.deny != "" {
+			deny = regexp.MustCompile(tt.deny)
+		}
+		l.SetFilter(allow, deny)
+		for _, msg := range msgs {
+			l.Info(msg)
+		}
+		got := bytes.Replace(buf.Bytes(), []byte("app:INFO: "), nil, -1)
+		got = bytes.Replace(got, []byte("\n"), []byte(","), -1)
+		if string(got) != tt.want {
+			t.Errorf("%s: logged %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package log
+
+import "io"
+
+// Handler is where a Vlogger writes its formatted lines. RotateHandler and
+// its wrappers, LevelRouter, AsyncHandler, ConsoleHandler, MemoryHandler and
+// SyslogHandler all implement it.
+type Handler interface {
+	io.Writer
+	// Init prepares the handler for writing, panicking on failure
+	Init()
+	// Flush writes out anything buffered
+	Flush()
+	// Close flushes and releases the handler
+	Close()
+}
+
+// LevelHandler is implemented by handlers that treat lines by level, such
+// as LevelRouter. A Vlogger passes them the level of each message it logs;
+// lines written with Write, e.g. by the embedded log.Logger's Print methods,
+// have none.
+type LevelHandler interface {
+	WriteLevel(level int, data []byte) (int, error)
+}
+
+// dropCounter is implemented by handlers that count the messages a Sampler
+// dropped in their Stats.
+type dropCounter interface {
+	countDrop()
+}
+
+func (w *RotateHandler) countDrop() {
+	w.stats.drop()
+}
+
+// activeFiler is implemented by handlers writing to a RotateHandler's file,
+// directly or through a wrapper, for String and MarshalJSON.
+type activeFiler interface {
+	// activeFile returns FilePath and the size of the active file
+	activeFile() (string, int)
+}
+
+func (w *RotateHandler) activeFile() (string, int) {
+	w.startLock.Lock()
+	defer w.startLock.Unlock()
+	return w.FilePath, w.curSize
+}
+
+// NewWithHandler creates a logger writing to h, which it initializes. The
+// logger's Flush and Close are passed on to h.
+func NewWithHandler(name string, h Handler) *Vlogger {
+	h.Init()
+	l := newVlogger(name, h)
+	l.HandleMode = RotateModeCustom
+	l.handler = h
+	if rh, ok := h.(*RotateHandler); ok {
+		l.FilePath = rh.FilePath
+	}
+	return l
+}
@@ -0,0 +1,41 @@
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+// fakeHandler records the calls a Vlogger makes to it.
+type fakeHandler struct {
+	calls []string
+	lines []string
+}
+
+func (h *fakeHandler) Write(data []byte) (int, error) {
+	h.calls = append(h.calls, "Write")
+	h.lines = append(h.lines, string(data))
+	return len(data), nil
+}
+
+func (h *fakeHandler) Init()  { h.calls = append(h.calls, "Init") }
+func (h *fakeHandler) Flush() { h.calls = append(h.calls, "Flush") }
+func (h *fakeHandler) Close() { h.calls = append(h.calls, "Close") }
+
+func TestNewWithHandler(t *testing.T) {
+	h := &fakeHandler{}
+	l := NewWithHandler("app", h)
+	l.SetFlags(0)
+	l.Info("one")
+	l.Error("two")
+	l.Close()
+
+	if got := strings.Join(h.calls, ","); got != "Init,Write,Write,Flush,Close" {
+		t.Errorf("calls = %s", got)
+	}
+	if got := strings.Join(h.lines, ""); got != "app:INFO: one\napp:ERROR: two\n" {
+		t.Errorf("lines = %q", got)
+	}
+	if l.HandleMode != RotateModeCustom || l.FilePath != "" {
+		t.Errorf("mode %d, file %q", l.HandleMode, l.FilePath)
+	}
+}
@@ -0,0 +1,75 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// loggerInfo is what String and MarshalJSON report about a logger.
+type loggerInfo struct {
+	Name  string `json:"name"`
+	File  string `json:"file,omitempty"`
+	Mode  string `json:"mode"`
+	Level string `json:"level"`
+	Size  int    `json:"size"`
+}
+
+func (l *Vlogger) info() loggerInfo {
+	info := loggerInfo{
+		Name:  l.Name,
+		Mode:  modeName(l.HandleMode),
+		Level: LevelName(l.GetLevel()),
+	}
+	// SetFilePath changes the handler's FilePath under its lock; l.FilePath
+	// is only for handlers that don't write to a file
+	if h, ok := l.handler.(activeFiler); ok {
+		info.File, info.Size = h.activeFile()
+	} else {
+		info.File = l.FilePath
+	}
+	return info
+}
+
+// modeName returns the config file name of a RotateMode constant.
+func modeName(mode int) string {
+	if mode == RotateModeCustom {
+		return "custom"
+	}
+	for name, m := range modeNames {
+		if m == mode {
+			return name
+		}
+	}
+	return strconv.Itoa(mode)
+}
+
+// String describes the logger's name, file, rotate mode, level and the
+// size of its active file.
+func (l *Vlogger) String() string {
+	info := l.info()
+	return fmt.Sprintf("%s(file=%q mode=%s level=%s size=%d)",
+		info.Name, info.File, info.Mode, info.Level, info.Size)
+}
+
+// MarshalJSON reports what String does as a JSON object, for debug
+// endpoints.
+func (l *Vlogger) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.info())
+}
+
+// List returns the managed loggers, sorted by name.
+func List() []*Vlogger {
+	bose.mu.Lock()
+	loggers := make([]*Vlogger, 0, len(bose.loggers))
+	for _, l := range bose.loggers {
+		loggers = append(loggers, l)
+	}
+	bose.mu.Unlock()
+
+	sort.Slice(loggers, func(i, j int) bool {
+		return loggers[i].Name < loggers[j].Name
+	})
+	return loggers
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoggerJSON(t *testing.T) {
+	dir := useLogDir(t)
+	b := GetLogger("b", RotateModeHour)
+	a := GetLogger("a", RotateModeNoRotate)
+	a.SetLevel(LevelWarn)
+	a.handler.Write([]byte("12345\n"))
+
+	got := List()
+	if len(got) != 2 || got[0] != a || got[1] != b {
+		t.Fatalf("List() = %v, want [a b]", got)
+	}
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shape []map[string]interface{}
+	if err := json.Unmarshal(data, &shape); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":  "a",
+		"file":  filepath.Join(dir, "a.log"),
+		"mode":  "none",
+		"level": "WARN",
+		"size":  float64(6),
+	}
+	if len(shape[0]) != len(want) {
+		t.Errorf("JSON %s has keys %v", data, shape[0])
+	}
+	for k, v := range want {
+		if shape[0][k] != v {
+			t.Errorf("%s = %#v, want %#v", k, shape[0][k], v)
+		}
+	}
+	if shape[1]["mode"] != "hour" {
+		t.Errorf("mode of b = %#v", shape[1]["mode"])
+	}
+
+	wantStr := fmt.Sprintf("a(file=%q mode=none level=WARN size=6)", filepath.Join(dir, "a.log"))
+	if s := a.String(); s != wantStr {
+		t.Errorf("String() = %s, want %s", s, wantStr)
+	}
+}
+
+func TestLoggerJSONWithoutFile(t *testing.T) {
+	data, err := json.Marshal(NewWriter("w", ioutil.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shape map[string]interface{}
+	if err := json.Unmarshal(data, &shape); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := shape["file"]; ok || shape["size"] != float64(0) {
+		t.Errorf("JSON = %s, want no file and size 0", data)
+	}
+}
+
+func TestLoggerJSONWrappedHandler(t *testing.T) {
+	dir := t.TempDir()
+	inner := func(name string) *RotateHandler {
+		return NewDefaultHandler(filepath.Join(dir, name))
+	}
+	for name, h := range map[string]Handler{
+		"buffered.log": NewBufferedHandler(inner("buffered.log"), 64<<10, time.Hour),
+		"primary.log":  NewLevelRouter(inner("primary.log"), LevelError, inner("secondary.log")),
+		"async.log":    NewAsyncHandler(inner("async.log"), 8),
+	} {
+		l := NewWithHandler("w", h)
+		h.Write([]byte("12345\n"))
+		h.Flush()
+		info := l.info()
+		l.Close()
+		if want := filepath.Join(dir, name); info.File != want || info.Size != 6 {
+			t.Errorf("%T: file %q size %d, want %q size 6", h, info.File, info.Size, want)
+		}
+	}
+}
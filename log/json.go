@@ -0,0 +1,65 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Output formats for Vlogger.Format.
+const (
+	// FormatText writes "name:time LEVEL: msg key=value" lines.
+	FormatText = iota
+	// FormatJSON writes one JSON object per line with ts, level, name, msg
+	// and any fields.
+	FormatJSON
+)
+
+const jsonTimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// formatJSON renders a message as a single JSON line. Fields follow the
+// fixed keys, sorted by key; caller is left out when empty.
+func formatJSON(t time.Time, level int, name, caller, msg string, fields Fields) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"ts":`)
+	writeJSONValue(&b, t.Format(jsonTimeLayout))
+	b.WriteString(`,"level":`)
+	writeJSONValue(&b, LevelName(level))
+	b.WriteString(`,"name":`)
+	writeJSONValue(&b, name)
+	if caller != "" {
+		b.WriteString(`,"caller":`)
+		writeJSONValue(&b, caller)
+	}
+	b.WriteString(`,"msg":`)
+	writeJSONValue(&b, msg)
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(',')
+		writeJSONValue(&b, k)
+		b.WriteByte(':')
+		writeJSONValue(&b, fields[k])
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// writeJSONValue writes v as JSON, falling back to its fmt representation
+// when it cannot be marshalled.
+func writeJSONValue(b *bytes.Buffer, v interface{}) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
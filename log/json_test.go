@@ -0,0 +1,94 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("App", &buf)
+	l.Format = FormatJSON
+	msg := "say \"hi\"\nand\tbye \\ done"
+	l.WithFields(Fields{"user": "bob", "n": 3}).Error(msg)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal %q: %s", buf.String(), err)
+	}
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("line %q is not a single line", buf.String())
+	}
+	want := map[string]interface{}{
+		"level": "ERROR",
+		"name":  "App",
+		"msg":   msg,
+		"user":  "bob",
+		"n":     float64(3),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, got[k], v)
+		}
+	}
+	if _, ok := got["ts"].(string); !ok {
+		t.Errorf("ts = %#v, want a string", got["ts"])
+	}
+}
+
+func TestJSONConcurrent(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.Format = FormatJSON
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Infof("json %d", j)
+				l.Println("text")
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 1600 {
+		t.Fatalf("got %d lines, want 1600", len(lines))
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "{") {
+			if !json.Valid([]byte(line)) {
+				t.Fatalf("invalid JSON line %q", line)
+			}
+		} else if !strings.HasSuffix(line, " text") {
+			t.Fatalf("mangled line %q", line)
+		}
+	}
+}
+
+func TestJSONEscapeControl(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.Format = FormatJSON
+	fields := Fields{"a": "two\nlines", "b": "tab\there", "c": `say "hi"`, "d": "bell\x07"}
+	l.WithFields(fields).Info("msg")
+
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("line %q is not a single line", buf.String())
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal %q: %s", buf.String(), err)
+	}
+	for k, v := range fields {
+		if got[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, got[k], v)
+		}
+	}
+}
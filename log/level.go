@@ -0,0 +1,384 @@
+package log
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// Log levels, from most to least verbose. The zero value is LevelInfo.
+const (
+	LevelDebug = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+var levelNames = map[int]string{
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelWarn:  "WARN",
+	LevelError: "ERROR",
+	LevelFatal: "FATAL",
+}
+
+// LevelName returns the name printed in front of messages at level.
+func LevelName(level int) string {
+	if name, ok := levelNames[level]; ok {
+		return name
+	}
+	return fmt.Sprintf("LEVEL(%d)", level)
+}
+
+// ParseLevel returns the level named name, ignoring case.
+func ParseLevel(name string) (int, error) {
+	for level, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// enabled reports whether messages at level pass the logger's threshold.
+func (l *Vlogger) enabled(level int) bool {
+	if l.discard {
+		return false
+	}
+	if level == LevelDebug && IsDebug() {
+		return true
+	}
+	return level >= l.GetLevel()
+}
+
+// SetLevel sets the minimum level written; lower levels are dropped. It is
+// safe to call while other goroutines are logging. On a Child it stops the
+// level following the parent's.
+func (l *Vlogger) SetLevel(level int) {
+	atomic.StoreInt32(&l.level, int32(level))
+	atomic.StoreInt32(&l.levelSet, 1)
+}
+
+// GetLevel returns the minimum level written.
+func (l *Vlogger) GetLevel() int {
+	if l.parent != nil && atomic.LoadInt32(&l.levelSet) == 0 {
+		return l.parent.GetLevel()
+	}
+	return int(atomic.LoadInt32(&l.level))
+}
+
+// output writes msg and fields prefixed with the level name, attributing it
+// to the caller of the exported logging method.
+func (l *Vlogger) output(level int, msg string, fields Fields) {
+	if l.dropped(level, msg) {
+		return
+	}
+	var pc uintptr
+	if l.wantsCaller() {
+		pc = callerPC(3 + l.CallerSkip)
+	}
+	l.emit(level, msg, fields, pc)
+}
+
+// outputAt is output for a message logged at pc, such as a slog.Record's.
+func (l *Vlogger) outputAt(level int, msg string, fields Fields, pc uintptr) {
+	if !l.dropped(level, msg) {
+		l.emit(level, msg, fields, pc)
+	}
+}
+
+// dropped reports whether the filter or Sampler drops msg.
+func (l *Vlogger) dropped(level int, msg string) bool {
+	if l.filtered(msg) {
+		return true
+	}
+	if l.Sampler != nil && level < LevelFatal && !l.Sampler.allow(level, msg, time.Now()) {
+		if h, ok := l.handler.(dropCounter); ok {
+			h.countDrop()
+		}
+		return true
+	}
+	return false
+}
+
+// wantsCaller reports whether lines show where they were logged.
+func (l *Vlogger) wantsCaller() bool {
+	return l.Caller || l.Flags()&(log.Lshortfile|log.Llongfile) != 0
+}
+
+// emit writes out a message that passed the filters.
+func (l *Vlogger) emit(level int, msg string, fields Fields, pc uintptr) {
+	m := message{level: level, msg: l.truncate(strings.TrimSuffix(msg, "\n")), fields: withGlobalFields(fields), pc: pc}
+	if l.dedup != nil {
+		l.dedup.output(l, m)
+		return
+	}
+	l.write(m)
+}
+
+// truncate cuts msg to MaxMessageSize bytes, on a character boundary, and
+// marks how much was left out.
+func (l *Vlogger) truncate(msg string) string {
+	if l.MaxMessageSize <= 0 || len(msg) <= l.MaxMessageSize {
+		return msg
+	}
+	n := l.MaxMessageSize
+	for n > 0 && !utf8.RuneStart(msg[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s...[truncated %d bytes]", msg[:n], len(msg)-n)
+}
+
+// message is a log message on its way to the handler.
+type message struct {
+	level  int
+	msg    string
+	fields Fields
+	// pc is where the message was logged, 0 if unknown
+	pc uintptr
+}
+
+// write formats m and writes it out.
+func (l *Vlogger) write(m message) {
+	if l.Format == FormatJSON {
+		l.writeLine(m.level, formatJSON(l.now(), m.level, l.Name, l.caller(m), m.msg, m.fields))
+		return
+	}
+	bp := lineBufPool.Get().(*[]byte)
+	b := l.formatText((*bp)[:0], l.now(), m)
+	l.writeLine(m.level, b)
+	if cap(b) <= maxPooledLine {
+		*bp = b
+		lineBufPool.Put(bp)
+	}
+}
+
+// lineBufPool holds the buffers text lines are formatted in; handlers don't
+// keep the data passed to Write, so they are reused once written.
+var lineBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// maxPooledLine keeps the buffers of unusually long lines out of the pool.
+const maxPooledLine = 64 << 10
+
+// formatText appends m to b the way l.Output would, with the prefix and
+// flags of the embedded log.Logger, stamping it with t.
+func (l *Vlogger) formatText(b []byte, t time.Time, m message) []byte {
+	prefix, flags := l.Prefix(), l.Flags()
+	if flags&log.Lmsgprefix == 0 {
+		b = append(b, prefix...)
+	}
+	if flags&log.LUTC != 0 {
+		t = t.UTC()
+	}
+	if flags&log.Ldate != 0 {
+		b = t.AppendFormat(b, "2006/01/02 ")
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		b = t.AppendFormat(b, "15:04:05")
+		if flags&log.Lmicroseconds != 0 {
+			b = t.AppendFormat(b, ".000000")
+		}
+		b = append(b, ' ')
+	}
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		file, line := frameOf(m.pc)
+		if flags&log.Lshortfile != 0 {
+			file = filepath.Base(file)
+		}
+		b = append(b, file...)
+		b = append(b, ':')
+		b = strconv.AppendInt(b, int64(line), 10)
+		b = append(b, ": "...)
+	}
+	if flags&log.Lmsgprefix != 0 {
+		b = append(b, prefix...)
+	}
+
+	if l.timeLayout != "" {
+		b = t.AppendFormat(b, l.timeLayout)
+		b = append(b, ' ')
+	}
+	if l.Caller {
+		b = append(b, l.caller(m)...)
+		b = append(b, ": "...)
+	}
+	b = append(b, LevelName(m.level)...)
+	b = append(b, ": "...)
+	b = append(b, m.msg...)
+	if len(m.fields) > 0 {
+		if m.msg != "" {
+			b = append(b, ' ')
+		}
+		b = append(b, formatFields(m.fields)...)
+	}
+	return append(b, '\n')
+}
+
+// now is the time messages are stamped with.
+func (l *Vlogger) now() time.Time {
+	if l.utc {
+		return time.Now().UTC()
+	}
+	return time.Now()
+}
+
+// callerPC returns the program counter of the frame skip levels above its
+// caller, counted like runtime.Caller, or 0 if there is none.
+func callerPC(skip int) uintptr {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+1, pcs[:]) == 0 {
+		return 0
+	}
+	return pcs[0]
+}
+
+// frameOf returns the file and line of a pc from runtime.Callers.
+func frameOf(pc uintptr) (string, int) {
+	if pc == 0 {
+		return "???", 0
+	}
+	f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if f.File == "" {
+		return "???", 0
+	}
+	return f.File, f.Line
+}
+
+// caller returns "file.go:line" for where m was logged if Caller is set,
+// like log.Lshortfile.
+func (l *Vlogger) caller(m message) string {
+	if !l.Caller {
+		return ""
+	}
+	file, line := frameOf(m.pc)
+	return filepath.Base(file) + ":" + strconv.Itoa(line)
+}
+
+func (l *Vlogger) Debugf(format string, v ...interface{}) {
+	if l.enabled(LevelDebug) {
+		l.output(LevelDebug, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func (l *Vlogger) Info(v ...interface{}) {
+	if l.enabled(LevelInfo) {
+		l.output(LevelInfo, fmt.Sprintln(v...), nil)
+	}
+}
+
+func (l *Vlogger) Infof(format string, v ...interface{}) {
+	if l.enabled(LevelInfo) {
+		l.output(LevelInfo, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func (l *Vlogger) Warn(v ...interface{}) {
+	if l.enabled(LevelWarn) {
+		l.output(LevelWarn, fmt.Sprintln(v...), nil)
+	}
+}
+
+func (l *Vlogger) Warnf(format string, v ...interface{}) {
+	if l.enabled(LevelWarn) {
+		l.output(LevelWarn, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func (l *Vlogger) Errorf(format string, v ...interface{}) {
+	if l.enabled(LevelError) {
+		l.output(LevelError, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+// InfoString logs s at LevelInfo. Unlike Info it needs no interface{}
+// boxing and no fmt formatting, for hot paths.
+func (l *Vlogger) InfoString(s string) {
+	if l.enabled(LevelInfo) {
+		l.output(LevelInfo, s, nil)
+	}
+}
+
+// InfoKV logs a key=val field at LevelInfo, see InfoString. In text lines
+// the pair is formatted straight into the message.
+func (l *Vlogger) InfoKV(key, val string) {
+	if l.enabled(LevelInfo) {
+		msg, fields := l.kv(key, val)
+		l.output(LevelInfo, msg, fields)
+	}
+}
+
+// ErrorString logs s at LevelError, see InfoString.
+func (l *Vlogger) ErrorString(s string) {
+	if l.enabled(LevelError) {
+		l.output(LevelError, s, nil)
+	}
+}
+
+// ErrorKV logs a key=val field at LevelError, see InfoKV.
+func (l *Vlogger) ErrorKV(key, val string) {
+	if l.enabled(LevelError) {
+		msg, fields := l.kv(key, val)
+		l.output(LevelError, msg, fields)
+	}
+}
+
+// kv returns the message and fields output takes for a key=val field. JSON
+// lines keep it a field.
+func (l *Vlogger) kv(key, val string) (string, Fields) {
+	if l.Format == FormatJSON {
+		return "", Fields{key: val}
+	}
+	return formatKV(key, val), nil
+}
+
+// Fatal logs at LevelFatal, flushes the log file and exits with status 1.
+func (l *Vlogger) Fatal(v ...interface{}) {
+	l.output(LevelFatal, fmt.Sprintln(v...), nil)
+	l.exit()
+}
+
+// Fatalf logs at LevelFatal, flushes the log file and exits with status 1.
+func (l *Vlogger) Fatalf(format string, v ...interface{}) {
+	l.output(LevelFatal, fmt.Sprintf(format, v...), nil)
+	l.exit()
+}
+
+// Panic logs at LevelFatal, flushes the log file and panics with the message.
+func (l *Vlogger) Panic(v ...interface{}) {
+	msg := fmt.Sprintln(v...)
+	l.output(LevelFatal, msg, nil)
+	l.Flush()
+	panic(strings.TrimSuffix(msg, "\n"))
+}
+
+// Panicf logs at LevelFatal, flushes the log file and panics with the
+// message.
+func (l *Vlogger) Panicf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	l.output(LevelFatal, msg, nil)
+	l.Flush()
+	panic(msg)
+}
+
+// osExit is replaced in tests to observe Fatal without exiting.
+var osExit = os.Exit
+
+// exit syncs the log file, so the fatal message is on disk, then exits.
+func (l *Vlogger) exit() {
+	l.Flush()
+	osExit(1)
+}
@@ -0,0 +1,270 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestKV(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(log.Lshortfile)
+	_, _, line, _ := runtime.Caller(0)
+	l.InfoKV("user", "bob smith")
+	l.ErrorKV("a key", "")
+
+	want := fmt.Sprintf("app:level_test.go:%d: INFO: user=\"bob smith\"\n"+
+		"app:level_test.go:%d: ERROR: \"a key\"=\"\"\n", line+1, line+2)
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestLevelThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	l.SetLevel(LevelWarn)
+	l.Debug("dropped")
+	l.Info("dropped")
+	l.Warnf("kept %d", 1)
+	l.Warn("kept", 2)
+
+	if want := "app:WARN: kept 1\napp:WARN: kept 2\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestErrorLine(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	l.Error("msg1", "msg2", 3)
+
+	if want := "app:ERROR: msg1 msg2 3\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestSetLevelWhileLogging(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			l.Info("line")
+			l.Warnf("line %d", i)
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		if i%2 == 0 {
+			l.SetLevel(LevelError)
+		} else {
+			l.SetLevel(LevelInfo)
+		}
+		l.GetLevel()
+	}
+	<-done
+
+	l.SetLevel(LevelError)
+	buf.Reset()
+	l.Warn("dropped")
+	if buf.Len() != 0 || l.GetLevel() != LevelError {
+		t.Errorf("after SetLevel(LevelError): level %d, output %q", l.GetLevel(), buf.String())
+	}
+}
+
+func TestCaller(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	l.Caller = true
+	_, _, line, _ := runtime.Caller(0)
+	l.Info("direct")
+	logVia(l)
+
+	want := fmt.Sprintf("app:level_test.go:%d: INFO: direct\n"+
+		"app:level_test.go:%d: INFO: wrapped\n", line+1, line+2)
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+// logVia logs through a wrapper, reporting its caller with CallerSkip.
+func logVia(l *Vlogger) {
+	l.CallerSkip = 1
+	defer func() { l.CallerSkip = 0 }()
+	l.Info("wrapped")
+}
+
+func TestStringAllocs(t *testing.T) {
+	l := NewWriter("app", ioutil.Discard)
+	for name, fn := range map[string]func(){
+		"InfoString": func() { l.InfoString("request served") },
+		"InfoKV":     func() { l.InfoKV("path", "/index.html") },
+	} {
+		// only InfoKV's message is allocated
+		max := 0.0
+		if name == "InfoKV" {
+			max = 1
+		}
+		if n := testing.AllocsPerRun(100, fn); n > max {
+			t.Errorf("%s: %v allocs per line, want at most %v", name, n, max)
+		}
+	}
+}
+
+func benchmarkLogger() *Vlogger {
+	return NewWriter("bench", ioutil.Discard)
+}
+
+func BenchmarkInfo(b *testing.B) {
+	l := benchmarkLogger()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Info("request served")
+	}
+}
+
+func BenchmarkInfoString(b *testing.B) {
+	l := benchmarkLogger()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.InfoString("request served")
+	}
+}
+
+func BenchmarkWithField(b *testing.B) {
+	l := benchmarkLogger()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.WithField("path", "/index.html").Info()
+	}
+}
+
+func BenchmarkInfoKV(b *testing.B) {
+	l := benchmarkLogger()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.InfoKV("path", "/index.html")
+	}
+}
+
+func BenchmarkError(b *testing.B) {
+	l := benchmarkLogger()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Error("request failed")
+	}
+}
+
+func BenchmarkErrorString(b *testing.B) {
+	l := benchmarkLogger()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.ErrorString("request failed")
+	}
+}
+
+func TestDiscardAllocs(t *testing.T) {
+	l := NewDiscard("app")
+	n := testing.AllocsPerRun(100, func() {
+		l.Infof("request %d served", 42)
+		l.Error("request failed")
+		l.InfoKV("path", "/")
+	})
+	if n != 0 {
+		t.Errorf("%v allocs per run, want none", n)
+	}
+}
+
+func BenchmarkDiscard(b *testing.B) {
+	l := NewDiscard("bench")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Info("request served")
+	}
+}
+
+// catchExit replaces osExit until the test ends, recording the exit code.
+func catchExit(t *testing.T) *int {
+	t.Helper()
+	code := -1
+	osExit = func(c int) { code = c }
+	t.Cleanup(func() { osExit = os.Exit })
+	return &code
+}
+
+func TestFatalfFlushesBeforeExit(t *testing.T) {
+	code := catchExit(t)
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.mw.SetBufferSize(4096)
+	})
+	l := NewWithHandler("app", h)
+
+	l.Fatalf("disk %s is gone", "sda")
+	if *code != 1 {
+		t.Errorf("exit code = %d, want 1", *code)
+	}
+	// read before any Close, as a real exit would leave it
+	if got := readFile(t, h.FilePath); !strings.Contains(got, "FATAL: disk sda is gone") {
+		t.Errorf("file = %q, want the fatal line", got)
+	}
+}
+
+func TestPanicf(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.mw.SetBufferSize(4096)
+	})
+	l := NewWithHandler("app", h)
+
+	defer func() {
+		if r := recover(); r != "bad state 7" {
+			t.Errorf("panic = %#v, want the message", r)
+		}
+		if got := readFile(t, h.FilePath); !strings.Contains(got, "FATAL: bad state 7") {
+			t.Errorf("file = %q, want the fatal line", got)
+		}
+	}()
+	l.Panicf("bad state %d", 7)
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf, WithMaxMessageSize(5))
+	l.SetFlags(0)
+	l.InfoString("0123456789")
+	l.InfoString("abcdéf") // é is 2 bytes, cut before it rather than in it
+	l.InfoString("short")
+
+	want := "app:INFO: 01234...[truncated 5 bytes]\n" +
+		"app:INFO: abcd...[truncated 3 bytes]\n" +
+		"app:INFO: short\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestMaxMessageSizeJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf, WithMaxMessageSize(4))
+	l.Format = FormatJSON
+	l.InfoString("ab\"\"\"\"\"")
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("truncated line %q is not JSON: %s", buf.String(), err)
+	}
+	if want := "ab\"\"...[truncated 3 bytes]"; got["msg"] != want {
+		t.Errorf("msg = %q, want %q", got["msg"], want)
+	}
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"sync"
+)
+
+// LineBufferedHandler is a RotateHandler for writers that send lines in
+// fragments: fragments are held until their line is complete, so the file
+// only ever receives whole lines. Flush and Close write out a pending partial
+// line, ended with a delimiter.
+type LineBufferedHandler struct {
+	*RotateHandler
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func NewLineBufferedHandler(inner *RotateHandler) *LineBufferedHandler {
+	return &LineBufferedHandler{RotateHandler: inner}
+}
+
+// Write writes the lines data completes and holds on to the rest.
+func (h *LineBufferedHandler) Write(data []byte) (int, error) {
+	delim := h.lineDelimiter()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.partial = append(h.partial, data...)
+	end := bytes.LastIndexByte(h.partial, delim)
+	if end < 0 {
+		return len(data), nil
+	}
+	complete := h.partial[:end+1]
+	var lines [][]byte
+	for len(complete) > 0 {
+		i := bytes.IndexByte(complete, delim)
+		lines = append(lines, complete[:i+1])
+		complete = complete[i+1:]
+	}
+	_, err := h.RotateHandler.WriteLines(lines)
+	// WriteLines copied the lines, the rest can move to the front
+	h.partial = append(h.partial[:0], h.partial[end+1:]...)
+	return len(data), err
+}
+
+func (h *LineBufferedHandler) WriteString(s string) (int, error) {
+	return h.Write([]byte(s))
+}
+
+// flushPartial writes a pending partial line. Must hold mu.
+func (h *LineBufferedHandler) flushPartial() {
+	if len(h.partial) == 0 {
+		return
+	}
+	line := append(h.partial, h.lineDelimiter())
+	h.partial = nil
+	h.RotateHandler.Write(line)
+}
+
+func (h *LineBufferedHandler) Flush() {
+	h.mu.Lock()
+	h.flushPartial()
+	h.mu.Unlock()
+	h.RotateHandler.Flush()
+}
+
+func (h *LineBufferedHandler) Close() {
+	h.mu.Lock()
+	h.flushPartial()
+	h.mu.Unlock()
+	h.RotateHandler.Close()
+}
@@ -0,0 +1,42 @@
+package log
+
+import "testing"
+
+func TestLineBufferedHandler(t *testing.T) {
+	inner := newTestHandler(t, nil)
+	h := NewLineBufferedHandler(inner)
+	for _, frag := range []string{"fir", "st\nsec", "ond\nthi", "rd"} {
+		if n, err := h.Write([]byte(frag)); n != len(frag) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", frag, n, err)
+		}
+	}
+	if got := readFile(t, inner.FilePath); got != "first\nsecond\n" {
+		t.Errorf("file = %q, want only the whole lines", got)
+	}
+	if n := inner.CurrentLines(); n != 2 {
+		t.Errorf("CurrentLines = %d, want 2", n)
+	}
+
+	h.Flush()
+	if got := readFile(t, inner.FilePath); got != "first\nsecond\nthird\n" {
+		t.Errorf("file after Flush = %q", got)
+	}
+	h.WriteString("last")
+	h.Close()
+	if got := readFile(t, inner.FilePath); got != "first\nsecond\nthird\nlast\n" {
+		t.Errorf("file after Close = %q", got)
+	}
+}
+
+func TestLineBufferedHandlerDelimiter(t *testing.T) {
+	inner := newTestHandler(t, func(h *RotateHandler) {
+		h.LineDelimiter = ';'
+	})
+	h := NewLineBufferedHandler(inner)
+	h.Write([]byte("a;b"))
+	h.Write([]byte(";c"))
+	h.Close()
+	if got := readFile(t, inner.FilePath); got != "a;b;c;" {
+		t.Errorf("file = %q", got)
+	}
+}
@@ -1,11 +1,15 @@
 package log
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -15,92 +19,395 @@ const (
 	RotateMode16M
 	RotateMode256M
 	RotateModeMillion
+	RotateModeHour
 )
 
+// Debug is kept for compatibility. It is read once, when the first logger or
+// handler is created, so it must be set before that: later changes, setting
+// it back to false included, have no effect. Use SetDebug instead.
+//
+// Deprecated: use SetDebug and IsDebug.
 var Debug = false
 
+var (
+	debug         int32
+	loadDebugOnce sync.Once
+)
+
+// SetDebug turns debug output on or off for every logger. It is safe to call
+// while other goroutines are logging.
+func SetDebug(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&debug, v)
+}
+
+// IsDebug reports whether debug output was turned on by SetDebug, or by
+// Debug before the first logger was created.
+func IsDebug() bool {
+	return atomic.LoadInt32(&debug) == 1
+}
+
+// loadDebug turns debug output on if Debug was set, the first time it is
+// called.
+func loadDebug() {
+	loadDebugOnce.Do(func() {
+		if Debug {
+			atomic.StoreInt32(&debug, 1)
+		}
+	})
+}
+
+// internalErrors is where the package reports errors it can't return, such
+// as failed background rotations.
+var internalErrors = struct {
+	sync.Mutex
+	w io.Writer
+}{w: os.Stderr}
+
+// SetInternalErrorWriter sends the package's own error messages to w rather
+// than os.Stderr; nil silences them. Handlers with OnError set report there
+// instead.
+func SetInternalErrorWriter(w io.Writer) {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	internalErrors.Lock()
+	defer internalErrors.Unlock()
+	internalErrors.w = w
+}
+
+// internalErrorf writes an error message to the internal error writer.
+func internalErrorf(format string, v ...interface{}) {
+	internalErrors.Lock()
+	defer internalErrors.Unlock()
+	fmt.Fprintf(internalErrors.w, format, v...)
+}
+
 type Vlogger struct {
 	*log.Logger
 	Name       string
 	FilePath   string
 	HandleMode int
+	// Format is FormatText or FormatJSON
+	Format int
+	// Caller adds the calling file's base name and line to each message,
+	// like log.Lshortfile; CallerSkip skips extra frames for wrappers
+	Caller     bool
+	CallerSkip int
+	// Sampler, if set, drops repeats of frequent messages
+	Sampler *Sampler
+	// MaxMessageSize, if set, truncates longer messages to that many bytes
+	MaxMessageSize int
+	// level is the minimum level written, see SetLevel. A Child follows its
+	// parent's level until levelSet is set by its own SetLevel
+	level    int32
+	levelSet int32
+	parent   *Vlogger
+	// timeLayout and utc control timestamps, see WithTimeLayout and WithUTC
+	timeLayout string
+	utc        bool
+	// dedup, if set, collapses repeated messages, see WithDedup
+	dedup *dedup
+	// discard drops every message, see NewDiscard
+	discard bool
+	// filter holds a *filter, see SetFilter
+	filter atomic.Value
+
+	// out serializes writes, see lockedWriter
+	out     *lockedWriter
+	handler Handler
 }
 
 func New(name, fp string, mode int) *Vlogger {
+	l, err := NewE(name, fp, mode)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// NewE is like New but returns an error instead of panicking when the log
+// file cannot be opened.
+func NewE(name, fp string, mode int) (*Vlogger, error) {
+	return NewWithOptions(name, fp, withMode(mode))
+}
 
-	var handler *RotateHandler
+// NewInDir is like New but puts the file in the directory set by SetLogDir,
+// named after the logger like GetLogger does. The logger is not registered
+// with the manager.
+func NewInDir(name string, mode int) *Vlogger {
+	bose.mu.Lock()
+	fp := bose.path(name)
+	bose.mu.Unlock()
+	return New(name, fp, mode)
+}
+
+// newHandler returns the RotateHandler for one of the RotateMode constants.
+func newHandler(fp string, mode int) *RotateHandler {
 	switch mode {
 	case RotateModeNoRotate:
-		handler = NewDefaultHandler(fp)
+		return NewDefaultHandler(fp)
 	case RotateModeWeek:
-		handler = NewDailyRotateHandler(fp, 7)
+		return NewDailyRotateHandler(fp, 7)
 	case RotateModeMonth:
-		handler = NewDailyRotateHandler(fp, 30)
+		return NewDailyRotateHandler(fp, 30)
 	case RotateMode16M:
-		handler = NewSizeRotateHandler(fp, 1<<24)
+		return NewSizeRotateHandler(fp, 1<<24)
 	case RotateMode256M:
-		handler = NewSizeRotateHandler(fp, 1<<28)
+		return NewSizeRotateHandler(fp, 1<<28)
 	case RotateModeMillion:
-		handler = NewLinesRotateHandler(fp, 1000000)
+		return NewLinesRotateHandler(fp, 1000000)
+	case RotateModeHour:
+		return NewHourlyRotateHandler(fp, 24)
 	default:
-		handler = NewDefaultHandler(fp)
+		return NewDefaultHandler(fp)
 	}
-	handler.Init()
-	logger := log.New(handler, strings.ToLower(name)+":", log.Lmicroseconds)
-	l := &Vlogger{
-		Logger:     logger,
-		Name:       name,
-		HandleMode: mode,
+}
+
+// newLogger opens handler and wraps it in a Vlogger.
+func newLogger(name, fp string, mode int, handler *RotateHandler) (*Vlogger, error) {
+	if err := handler.InitE(); err != nil {
+		return nil, err
 	}
+	l := newVlogger(name, handler)
+	l.FilePath = fp
+	l.HandleMode = mode
+	l.handler = handler
+	return l, nil
+}
 
-	return l
+// newVlogger returns a logger writing to w with the default prefix and
+// flags.
+func newVlogger(name string, w io.Writer) *Vlogger {
+	loadDebug()
+	out := &lockedWriter{w: w}
+	return &Vlogger{
+		Logger: log.New(out, strings.ToLower(name)+":", log.Lmicroseconds),
+		Name:   name,
+		out:    out,
+	}
 }
 
 func (l *Vlogger) Debug(v ...interface{}) {
-	if !Debug {
+	if !l.enabled(LevelDebug) {
 		return
 	}
-	l.Println("Debug: ", v)
+	l.output(LevelDebug, fmt.Sprintln(v...), nil)
 }
 
 func (l *Vlogger) DebugFilter(ok bool, v ...interface{}) {
-	if Debug && ok{
-		l.Println("Debug: ", v)
+	if ok && l.enabled(LevelDebug) {
+		l.output(LevelDebug, fmt.Sprintln(v...), nil)
 	}
 }
 
 func (l *Vlogger) Error(v ...interface{}) {
-	l.Printf("Error: %s \n", v)
+	if !l.enabled(LevelError) {
+		return
+	}
+	l.output(LevelError, fmt.Sprintln(v...), nil)
+}
+
+// NewDiscard returns a logger that drops everything, for tests, benchmarks
+// and disabled code paths. Its logging methods return before formatting.
+func NewDiscard(name string) *Vlogger {
+	return &Vlogger{
+		Logger:     log.New(ioutil.Discard, "", 0),
+		Name:       name,
+		HandleMode: RotateModeCustom,
+		discard:    true,
+	}
 }
 
+// Flush writes out any buffered messages and syncs the log file.
+func (l *Vlogger) Flush() {
+	if l.dedup != nil {
+		l.dedup.flush(l)
+	}
+	if l.handler != nil {
+		l.handler.Flush()
+	}
+}
+
+// Close flushes and closes the log file. Closing a Child only flushes, the
+// file belongs to the parent.
+func (l *Vlogger) Close() {
+	l.Flush()
+	if l.handler != nil && l.parent == nil {
+		l.handler.Close()
+	}
+}
 
 type manager struct {
 	mu      sync.Mutex
 	baseDir string
 	loggers map[string]*Vlogger
+	// creating holds the loggers being opened outside of mu, so callers
+	// asking for the same name wait for that one instead of opening another
+	creating map[string]*creation
+}
+
+// path returns the file of the managed logger name. Must hold mu.
+func (m *manager) path(name string) string {
+	return filepath.Join(m.baseDir, strings.ToLower(name)+".log")
+}
+
+// creation is a logger being created by getLogger.
+type creation struct {
+	done chan struct{}
+	l    *Vlogger
+	err  error
 }
 
 var bose = &manager{
-	baseDir: "./",
-	loggers: make(map[string]*Vlogger),
+	baseDir:  "./",
+	loggers:  make(map[string]*Vlogger),
+	creating: make(map[string]*creation),
 }
 
 func SetLogDir(logDir string) {
-	if _, err := os.Stat(logDir); err != nil {
+	if err := SetLogDirE(logDir); err != nil {
 		log.Panicf("error when set log dir : %s", err)
 	}
+}
+
+// SetLogDirE sets the directory for loggers created by GetLogger, creating it
+// if it does not exist yet.
+func SetLogDirE(logDir string) error {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return err
+	}
+	bose.mu.Lock()
 	bose.baseDir = logDir
+	bose.mu.Unlock()
+	resetDefault(true)
+	return nil
 }
 
 func GetLogger(name string, mode int) *Vlogger {
+	l, err := GetLoggerE(name, mode)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// GetLoggerE is like GetLogger but returns an error instead of panicking when
+// a new logger's file cannot be opened.
+func GetLoggerE(name string, mode int) (*Vlogger, error) {
+	return GetLoggerWithOptionsE(name, withMode(mode))
+}
+
+// GetLoggerWithOptions is like GetLogger but configures a new logger with
+// opts, see NewWithOptions. If name is already registered the existing
+// logger is returned as is and opts are ignored.
+func GetLoggerWithOptions(name string, opts ...Option) *Vlogger {
+	l, err := GetLoggerWithOptionsE(name, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// GetLoggerWithOptionsE is like GetLoggerWithOptions but returns an error
+// instead of panicking when a new logger's file cannot be opened.
+func GetLoggerWithOptionsE(name string, opts ...Option) (*Vlogger, error) {
+	l, _, err := getLogger(name, opts)
+	return l, err
+}
+
+// getLogger returns the logger registered as name, creating it with opts if
+// there is none, and whether it did. The file is opened without holding
+// bose.mu, so only callers asking for the same new name wait for each other.
+func getLogger(name string, opts []Option) (*Vlogger, bool, error) {
+	bose.mu.Lock()
+	if l, ok := bose.loggers[name]; ok {
+		bose.mu.Unlock()
+		return l, false, nil
+	}
+	if c, ok := bose.creating[name]; ok {
+		bose.mu.Unlock()
+		<-c.done
+		return c.l, false, c.err
+	}
+	c := &creation{done: make(chan struct{})}
+	bose.creating[name] = c
+	fp := bose.path(name)
+	bose.mu.Unlock()
+
+	c.l, c.err = NewWithOptions(name, fp, opts...)
+
+	bose.mu.Lock()
+	delete(bose.creating, name)
+	if c.err == nil {
+		bose.loggers[name] = c.l
+	}
+	bose.mu.Unlock()
+	close(c.done)
+	return c.l, c.err == nil, c.err
+}
+
+// HasLogger reports whether a logger is registered under name.
+func HasLogger(name string) bool {
 	bose.mu.Lock()
 	defer bose.mu.Unlock()
+	_, ok := bose.loggers[name]
+	return ok
+}
 
-	if l, ok := bose.loggers[name]; ok {
-		return l
+// GetLoggerIfAbsent is like GetLogger but also reports whether the logger was
+// created by this call, so callers can detect a name registered twice.
+func GetLoggerIfAbsent(name string, mode int) (*Vlogger, bool) {
+	l, created, err := getLogger(name, []Option{withMode(mode)})
+	if err != nil {
+		panic(err)
+	}
+	return l, created
+}
+
+// Close flushes and closes the managed logger name and forgets it, so a later
+// GetLogger creates it afresh.
+func Close(name string) {
+	bose.mu.Lock()
+	l, ok := bose.loggers[name]
+	delete(bose.loggers, name)
+	bose.mu.Unlock()
+
+	if ok {
+		if name == defaultName {
+			resetDefault(false)
+		}
+		l.Close()
+	}
+}
+
+// FlushAll flushes every managed logger and syncs its file to disk, e.g.
+// before a planned failover. Logging can go on during and after it.
+func FlushAll() {
+	bose.mu.Lock()
+	loggers := make([]*Vlogger, 0, len(bose.loggers))
+	for _, l := range bose.loggers {
+		loggers = append(loggers, l)
+	}
+	bose.mu.Unlock()
+
+	for _, l := range loggers {
+		l.Flush()
+	}
+}
+
+// CloseAll flushes and closes every managed logger, for use on shutdown.
+func CloseAll() {
+	bose.mu.Lock()
+	loggers := bose.loggers
+	bose.loggers = make(map[string]*Vlogger)
+	bose.mu.Unlock()
+	resetDefault(false)
+
+	for _, l := range loggers {
+		l.Close()
 	}
-	fp := filepath.Join(bose.baseDir, strings.ToLower(name)+".log")
-	logger := New(name, fp, mode)
-	bose.loggers[name] = logger
-	return logger
 }
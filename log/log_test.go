@@ -0,0 +1,395 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestHandler returns an initialized handler writing to a file in a
+// temporary directory, closed when the test ends.
+func newTestHandler(t *testing.T, setup func(*RotateHandler)) *RotateHandler {
+	t.Helper()
+	h := NewDefaultHandler(filepath.Join(t.TempDir(), "test.log"))
+	if setup != nil {
+		setup(h)
+	}
+	if err := h.InitE(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+// readFile returns the content of path, failing the test if it can't.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// archives returns the rotated files next to h's file.
+func archives(t *testing.T, h *RotateHandler) []string {
+	t.Helper()
+	m, err := filepath.Glob(h.FilePath + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// countLinesIn returns the number of lines in s.
+func countLinesIn(s string) int {
+	return strings.Count(s, "\n")
+}
+
+// useLogDir points the managed loggers at a temporary directory, closing
+// them and restoring the previous directory and Default when the test ends.
+func useLogDir(t *testing.T) string {
+	t.Helper()
+	saveDefault(t)
+	dir := t.TempDir()
+	bose.mu.Lock()
+	old := bose.baseDir
+	bose.mu.Unlock()
+	SetLogDir(dir)
+	t.Cleanup(func() {
+		CloseAll()
+		bose.mu.Lock()
+		bose.baseDir = old
+		bose.mu.Unlock()
+	})
+	return dir
+}
+
+// saveDefault restores the Default logger's state when the test ends.
+func saveDefault(t *testing.T) {
+	t.Helper()
+	std.Lock()
+	l, explicit, dirSet := std.l, std.explicit, std.dirSet
+	std.Unlock()
+	t.Cleanup(func() {
+		std.Lock()
+		std.l, std.explicit, std.dirSet = l, explicit, dirSet
+		std.Unlock()
+	})
+}
+
+func TestDebugFlag(t *testing.T) {
+	defer SetDebug(false)
+	// as if no logger was created yet
+	loadDebugOnce = sync.Once{}
+	Debug = true
+	NewWriter("app", ioutil.Discard)
+	Debug = false
+	if !IsDebug() {
+		t.Error("Debug set before creating a logger was not picked up")
+	}
+	SetDebug(false)
+	if IsDebug() {
+		t.Error("SetDebug(false) did not turn debug off")
+	}
+
+	// Debug is only read for the first logger
+	Debug = true
+	NewWriter("app", ioutil.Discard)
+	Debug = false
+	if IsDebug() {
+		t.Error("Debug set after the first logger was created turned debug on")
+	}
+}
+
+func TestSetDebugWhileLogging(t *testing.T) {
+	defer SetDebug(false)
+	h := newTestHandler(t, nil)
+	l := NewWithHandler("app", h)
+	l.SetOutput(ioutil.Discard)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			SetDebug(i%2 == 0)
+			// old code assigning the variable doesn't race with writes
+			Debug = i%2 == 0
+		}
+		Debug = false
+	}()
+	for i := 0; i < 100; i++ {
+		l.Debugf("line %d", i)
+		h.Write([]byte("raw\n"))
+	}
+	<-done
+}
+
+func TestInitEUnwritable(t *testing.T) {
+	// a regular file where the log directory should be
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := ioutil.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fp := filepath.Join(blocker, "sub", "test.log")
+
+	if err := NewDefaultHandler(fp).InitE(); err == nil {
+		t.Error("InitE: no error")
+	}
+	if l, err := NewE("app", fp, RotateModeNoRotate); err == nil || l != nil {
+		t.Errorf("NewE = %v, %v, want an error", l, err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Init did not panic")
+			}
+		}()
+		NewDefaultHandler(fp).Init()
+	}()
+
+	dir := useLogDir(t)
+	// a directory where the managed logger's file should be
+	if err := os.Mkdir(filepath.Join(dir, "app.log"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetLoggerE("app", RotateModeNoRotate); err == nil {
+		t.Error("GetLoggerE: no error")
+	}
+	if HasLogger("app") {
+		t.Error("failed logger was registered")
+	}
+}
+
+func TestCloseAll(t *testing.T) {
+	useLogDir(t)
+	a := GetLogger("a", RotateModeNoRotate)
+	b := GetLogger("b", RotateModeNoRotate)
+	a.Info("from a")
+	b.Info("from b")
+
+	CloseAll()
+	if HasLogger("a") || HasLogger("b") {
+		t.Error("loggers still registered after CloseAll")
+	}
+	for _, l := range []*Vlogger{a, b} {
+		if got := readFile(t, l.FilePath); !strings.Contains(got, "from "+l.Name) {
+			t.Errorf("%s = %q", l.FilePath, got)
+		}
+		if _, err := l.handler.Write([]byte("late\n")); err != ErrWriterNotInitialized {
+			t.Errorf("%s still open after CloseAll: %v", l.Name, err)
+		}
+	}
+}
+
+func TestCloseOne(t *testing.T) {
+	useLogDir(t)
+	a := GetLogger("a", RotateModeNoRotate)
+	GetLogger("b", RotateModeNoRotate)
+
+	Close("a")
+	if HasLogger("a") || !HasLogger("b") {
+		t.Errorf("after Close(a): HasLogger(a) = %v, HasLogger(b) = %v", HasLogger("a"), HasLogger("b"))
+	}
+	if _, err := a.handler.Write([]byte("late\n")); err != ErrWriterNotInitialized {
+		t.Errorf("a still open after Close: %v", err)
+	}
+	if again := GetLogger("a", RotateModeNoRotate); again == a {
+		t.Error("GetLogger returned the closed logger")
+	}
+}
+
+func TestSetLogDirCreatesNested(t *testing.T) {
+	dir := filepath.Join(useLogDir(t), "a", "b", "c")
+	if err := SetLogDirE(dir); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		t.Fatalf("%s not created: %v", dir, err)
+	}
+	l := GetLogger("app", RotateModeNoRotate)
+	if want := filepath.Join(dir, "app.log"); l.FilePath != want {
+		t.Errorf("FilePath = %q, want %q", l.FilePath, want)
+	}
+
+	// a regular file in the way can't be made a directory
+	blocker := filepath.Join(dir, "blocker")
+	if err := ioutil.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLogDirE(filepath.Join(blocker, "sub")); err == nil {
+		t.Error("SetLogDirE under a file: no error")
+	}
+}
+
+func TestGetLoggerWithOptions(t *testing.T) {
+	dir := useLogDir(t)
+	l := GetLoggerWithOptions("api", WithMaxSize(1<<10), WithLevel(LevelWarn))
+	if l.FilePath != filepath.Join(dir, "api.log") || l.GetLevel() != LevelWarn {
+		t.Errorf("FilePath %s, level %d", l.FilePath, l.GetLevel())
+	}
+	if h := l.handler.(*RotateHandler); h.MaxSize != 1<<10 || !h.Rotatable {
+		t.Errorf("MaxSize = %d", h.MaxSize)
+	}
+
+	// the registered logger is reused, other options are ignored
+	again := GetLoggerWithOptions("api", WithLevel(LevelDebug))
+	if again != l || again.GetLevel() != LevelWarn {
+		t.Errorf("second call returned %p at level %d, want %p unchanged", again, again.GetLevel(), l)
+	}
+	if GetLogger("api", RotateModeWeek) != l {
+		t.Error("GetLogger did not reuse the logger")
+	}
+}
+
+func TestGetLoggerIfAbsent(t *testing.T) {
+	useLogDir(t)
+	if HasLogger("api") {
+		t.Fatal("HasLogger before GetLoggerIfAbsent")
+	}
+	l, created := GetLoggerIfAbsent("api", RotateModeNoRotate)
+	if !created || !HasLogger("api") {
+		t.Errorf("first call: created %v, HasLogger %v", created, HasLogger("api"))
+	}
+	again, created := GetLoggerIfAbsent("api", RotateModeHour)
+	if created || again != l {
+		t.Errorf("second call: created %v, returned %p, want %p", created, again, l)
+	}
+	if HasLogger("other") {
+		t.Error("HasLogger of an unknown name")
+	}
+}
+
+func TestGetLoggerConcurrentNames(t *testing.T) {
+	useLogDir(t)
+	const n = 32
+	loggers := make([][2]*Vlogger, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		// two goroutines race for each name
+		for j := 0; j < 2; j++ {
+			wg.Add(1)
+			go func(i, j int) {
+				defer wg.Done()
+				loggers[i][j] = GetLogger(fmt.Sprintf("svc%d", i), RotateModeNoRotate)
+			}(i, j)
+		}
+	}
+	wg.Wait()
+
+	seen := make(map[*Vlogger]bool)
+	for i, pair := range loggers {
+		if pair[0] == nil || pair[0] != pair[1] {
+			t.Fatalf("svc%d: got %p and %p, want one logger", i, pair[0], pair[1])
+		}
+		if seen[pair[0]] {
+			t.Fatalf("svc%d shares a logger with another name", i)
+		}
+		seen[pair[0]] = true
+		pair[0].Info("ready")
+		if got := readFile(t, pair[0].FilePath); countLinesIn(got) != 1 {
+			t.Errorf("svc%d file = %q", i, got)
+		}
+	}
+}
+
+func TestFlushAll(t *testing.T) {
+	useLogDir(t)
+	a := GetLogger("a", RotateModeNoRotate)
+	b := GetLogger("b", RotateModeNoRotate)
+	for _, l := range []*Vlogger{a, b} {
+		// hold lines in memory until flushed
+		handlerOf(l).mw.SetBufferSize(64 << 10)
+		l.Info("from " + l.Name)
+		if got := readFile(t, l.FilePath); got != "" {
+			t.Fatalf("%s = %q before FlushAll", l.FilePath, got)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			a.Info("during")
+		}
+	}()
+	FlushAll()
+	<-done
+	for _, l := range []*Vlogger{a, b} {
+		if got := readFile(t, l.FilePath); !strings.Contains(got, "from "+l.Name) {
+			t.Errorf("%s = %q after FlushAll", l.FilePath, got)
+		}
+	}
+	// the loggers stay usable
+	b.Info("after")
+	FlushAll()
+	if got := readFile(t, b.FilePath); !strings.Contains(got, "after") {
+		t.Errorf("%s = %q, missing a line logged after FlushAll", b.FilePath, got)
+	}
+}
+
+func TestSetInternalErrorWriter(t *testing.T) {
+	var buf bytes.Buffer
+	SetInternalErrorWriter(&buf)
+	defer SetInternalErrorWriter(os.Stderr)
+
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxLines = 1
+		h.Rotatable = true
+		h.NameFunc = func(base string, _ time.Time, seq int) string {
+			return filepath.Join(base+".missing", strconv.Itoa(seq))
+		}
+	})
+	h.Write([]byte("a\n"))
+	h.Write([]byte("b\n"))
+	if s := buf.String(); !strings.Contains(s, "rotate rename") {
+		t.Errorf("internal errors = %q, want the failed rotation", s)
+	}
+
+	SetInternalErrorWriter(nil)
+	buf.Reset()
+	h.Write([]byte("c\n"))
+	if buf.Len() != 0 {
+		t.Errorf("internal errors = %q after silencing", buf.String())
+	}
+}
+
+func TestNewInDir(t *testing.T) {
+	// an absolute log directory
+	dir := useLogDir(t)
+	l := NewInDir("API", RotateModeNoRotate)
+	defer l.Close()
+	if want := filepath.Join(dir, "api.log"); l.FilePath != want {
+		t.Errorf("FilePath = %s, want %s", l.FilePath, want)
+	}
+	if HasLogger("API") {
+		t.Error("NewInDir registered the logger")
+	}
+
+	// a relative one, resolved against the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	SetLogDir("logs")
+	rel := NewInDir("api", RotateModeNoRotate)
+	defer rel.Close()
+	rel.Info("relative")
+	if rel.FilePath != filepath.Join("logs", "api.log") {
+		t.Errorf("FilePath = %s", rel.FilePath)
+	}
+	if got := readFile(t, filepath.Join(dir, "logs", "api.log")); !strings.Contains(got, "relative") {
+		t.Errorf("file = %q", got)
+	}
+}
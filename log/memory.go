@@ -0,0 +1,60 @@
+package log
+
+import (
+	"strings"
+	"sync"
+)
+
+// MemoryHandler keeps written lines in memory, so tests can assert on log
+// output without touching the filesystem.
+type MemoryHandler struct {
+	mu    sync.Mutex
+	lines []string
+	// partial holds a trailing line not yet ended by a newline
+	partial string
+}
+
+func NewMemoryHandler() *MemoryHandler {
+	return &MemoryHandler{}
+}
+
+func (h *MemoryHandler) Write(data []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	parts := strings.Split(h.partial+string(data), "\n")
+	h.lines = append(h.lines, parts[:len(parts)-1]...)
+	h.partial = parts[len(parts)-1]
+	return len(data), nil
+}
+
+// Lines returns a copy of the complete lines written so far, without their
+// newlines.
+func (h *MemoryHandler) Lines() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.lines...)
+}
+
+// Reset discards everything written so far.
+func (h *MemoryHandler) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lines = nil
+	h.partial = ""
+}
+
+func (h *MemoryHandler) Init() {}
+
+// Flush turns a pending line without a newline into a complete line.
+func (h *MemoryHandler) Flush() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.partial != "" {
+		h.lines = append(h.lines, h.partial)
+		h.partial = ""
+	}
+}
+
+func (h *MemoryHandler) Close() {
+	h.Flush()
+}
@@ -0,0 +1,61 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMemoryHandler(t *testing.T) {
+	h := NewMemoryHandler()
+	h.Write([]byte("one\ntw"))
+	h.Write([]byte("o\nthree"))
+	if got := strings.Join(h.Lines(), "|"); got != "one|two" {
+		t.Errorf("Lines = %q", got)
+	}
+	h.Flush()
+	if got := strings.Join(h.Lines(), "|"); got != "one|two|three" {
+		t.Errorf("Lines after Flush = %q", got)
+	}
+	lines := h.Lines()
+	lines[0] = "changed"
+	if h.Lines()[0] != "one" {
+		t.Error("Lines does not return a copy")
+	}
+	h.Reset()
+	if len(h.Lines()) != 0 {
+		t.Errorf("Lines after Reset = %q", h.Lines())
+	}
+}
+
+func TestMemoryHandlerConcurrent(t *testing.T) {
+	h := NewMemoryHandler()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.Write([]byte("line\n"))
+				h.Lines()
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(h.Lines()); n != 800 {
+		t.Errorf("%d lines, want 800", n)
+	}
+}
+
+func ExampleMemoryHandler() {
+	h := NewMemoryHandler()
+	l := NewWithHandler("app", h)
+	l.SetFlags(0)
+	l.Warn("disk almost full")
+
+	for _, line := range h.Lines() {
+		fmt.Println(line)
+	}
+	// Output: app:WARN: disk almost full
+}
@@ -0,0 +1,105 @@
+package log
+
+import (
+	"io"
+	"strings"
+)
+
+// MultiHandler copies every write to each of its writers, like a tee.
+type MultiHandler struct {
+	writers []io.Writer
+}
+
+func NewMultiHandler(writers ...io.Writer) *MultiHandler {
+	return &MultiHandler{writers: writers}
+}
+
+// Write writes data to every writer, even after one fails, and returns the
+// first error encountered.
+func (h *MultiHandler) Write(data []byte) (int, error) {
+	var first error
+	for _, w := range h.writers {
+		n, err := w.Write(data)
+		if err == nil && n != len(data) {
+			err = io.ErrShortWrite
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return len(data), first
+}
+
+// WriteLevel is like Write but passes level on to the writers that are
+// LevelHandlers.
+func (h *MultiHandler) WriteLevel(level int, data []byte) (int, error) {
+	var first error
+	for _, w := range h.writers {
+		n, err := writeLevel(w, level, data)
+		if err == nil && n != len(data) {
+			err = io.ErrShortWrite
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return len(data), first
+}
+
+// Flush flushes every writer that supports it, returning a MultiError of
+// the failures if any.
+func (h *MultiHandler) Flush() error {
+	var errs MultiError
+	for _, w := range h.writers {
+		switch f := w.(type) {
+		case interface{ Flush() }:
+			f.Flush()
+		case interface{ Flush() error }:
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs.err()
+}
+
+// Close closes every writer that supports it, returning a MultiError of the
+// failures if any.
+func (h *MultiHandler) Close() error {
+	var errs MultiError
+	for _, w := range h.writers {
+		switch c := w.(type) {
+		case interface{ Close() }:
+			c.Close()
+		case io.Closer:
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs.err()
+}
+
+// MultiError is the errors of several writers failing together.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap lets errors.Is and errors.As look at each error.
+func (e MultiError) Unwrap() []error {
+	return e
+}
+
+// err returns e, or nil when it is empty.
+func (e MultiError) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,105 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// failWriter fails every Write with err.
+type failWriter struct {
+	err error
+}
+
+func (w failWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+func TestMultiHandler(t *testing.T) {
+	var a, b bytes.Buffer
+	h := NewMultiHandler(&a, &b)
+	l := NewWriter("app", h)
+	l.SetFlags(0)
+	l.Info("to both")
+	l.Error("again")
+
+	want := "app:INFO: to both\napp:ERROR: again\n"
+	if a.String() != want || b.String() != want {
+		t.Errorf("got %q and %q, want %q in both", a.String(), b.String(), want)
+	}
+}
+
+func TestMultiHandlerWriteError(t *testing.T) {
+	var a, b bytes.Buffer
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+	h := NewMultiHandler(&a, failWriter{errFirst}, failWriter{errSecond}, &b)
+	n, err := h.Write([]byte("line\n"))
+	if err != errFirst {
+		t.Errorf("err = %v, want the first error", err)
+	}
+	if n != 5 || a.String() != "line\n" || b.String() != "line\n" {
+		t.Errorf("n = %d, writers got %q and %q", n, a.String(), b.String())
+	}
+}
+
+func TestMultiHandlerFlushClose(t *testing.T) {
+	f1, f2 := newTestHandler(t, nil), newTestHandler(t, nil)
+	h := NewMultiHandler(f1, f2)
+	h.Write([]byte("line\n"))
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*RotateHandler{f1, f2} {
+		if _, err := f.Write([]byte("late\n")); err != ErrWriterNotInitialized {
+			t.Errorf("%s: Write after Close = %v, want it closed", f.FilePath, err)
+		}
+		if got := readFile(t, f.FilePath); got != "line\n" {
+			t.Errorf("%s = %q", f.FilePath, got)
+		}
+	}
+}
+
+// closeWriter is a writer whose Flush and Close fail with err, if set.
+type closeWriter struct {
+	bytes.Buffer
+	err    error
+	closed bool
+}
+
+func (w *closeWriter) Flush() error {
+	return w.err
+}
+
+func (w *closeWriter) Close() error {
+	w.closed = true
+	return w.err
+}
+
+func TestMultiHandlerCombinedErrors(t *testing.T) {
+	errA, errC := errors.New("a failed"), errors.New("c failed")
+	a, b, c := &closeWriter{err: errA}, &closeWriter{}, &closeWriter{err: errC}
+	h := NewMultiHandler(a, b, c)
+
+	for name, fn := range map[string]func() error{"Flush": h.Flush, "Close": h.Close} {
+		err := fn()
+		var me MultiError
+		if !errors.As(err, &me) || len(me) != 2 {
+			t.Fatalf("%s = %v, want a MultiError of 2", name, err)
+		}
+		if !errors.Is(err, errA) || !errors.Is(err, errC) {
+			t.Errorf("%s = %v, want both errors", name, err)
+		}
+		if err.Error() != "a failed; c failed" {
+			t.Errorf("%s message = %q", name, err.Error())
+		}
+	}
+	if !a.closed || !b.closed || !c.closed {
+		t.Error("Close stopped at a failing writer")
+	}
+	if err := NewMultiHandler(b).Close(); err != nil {
+		t.Errorf("Close without failures = %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package log
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// RotateModeCustom is the HandleMode of loggers configured by options
+// rather than one of the RotateMode constants.
+const RotateModeCustom = -1
+
+// Option configures a logger created by NewWithOptions. Options apply in
+// order, so when two set the same thing the last one wins. Limits of
+// different kinds combine: with both WithMaxSize and WithMaxDays the file
+// rotates on whichever comes first.
+type Option func(*config)
+
+type config struct {
+	handler    *RotateHandler
+	mode       int
+	level      int
+	timeLayout string
+	utc        bool
+	dedup      *dedup
+	prefix     *string
+	maxMessage int
+}
+
+// withMode starts from the handler New uses for mode.
+func withMode(mode int) Option {
+	return func(c *config) {
+		c.handler = newHandler(c.handler.FilePath, mode)
+		c.mode = mode
+	}
+}
+
+// WithMaxSize rotates the file once it reaches size bytes.
+func WithMaxSize(size int) Option {
+	return func(c *config) {
+		c.handler.MaxSize = size
+		c.handler.Rotatable = true
+	}
+}
+
+// WithMaxLines rotates the file once it holds lines lines.
+func WithMaxLines(lines int) Option {
+	return func(c *config) {
+		c.handler.MaxLines = lines
+		c.handler.Rotatable = true
+	}
+}
+
+// WithMaxDays rotates the file daily and removes rotated files older than
+// days days.
+func WithMaxDays(days int) Option {
+	return func(c *config) {
+		c.handler.MaxDays = days
+		c.handler.Rotatable = true
+	}
+}
+
+// WithMaxBackups keeps at most n rotated files.
+func WithMaxBackups(n int) Option {
+	return func(c *config) {
+		c.handler.MaxBackups = n
+	}
+}
+
+// WithMaxTotalSize keeps rotated files within size bytes in total.
+func WithMaxTotalSize(size int64) Option {
+	return func(c *config) {
+		c.handler.MaxTotalSize = size
+	}
+}
+
+// WithErrorCooldown stops writing to the file for d after a write error,
+// sending lines to fallback meanwhile, or dropping them if it is nil.
+func WithErrorCooldown(d time.Duration, fallback io.Writer) Option {
+	return func(c *config) {
+		c.handler.ErrorCooldown = d
+		c.handler.Fallback = fallback
+	}
+}
+
+// WithTransform rewrites each line with fn before it is written, after the
+// transforms of earlier WithTransform options.
+func WithTransform(fn func(line []byte) []byte) Option {
+	return func(c *config) {
+		c.handler.Transforms = append(c.handler.Transforms, fn)
+	}
+}
+
+// WithRotateOnStart archives a non-empty existing file when the logger is
+// created.
+func WithRotateOnStart() Option {
+	return func(c *config) {
+		c.handler.RotateOnStart = true
+	}
+}
+
+// WithCompress gzips rotated files.
+func WithCompress(compress bool) Option {
+	return func(c *config) {
+		c.handler.Compress = compress
+	}
+}
+
+// WithMaxMessageSize truncates messages longer than size bytes.
+func WithMaxMessageSize(size int) Option {
+	return func(c *config) {
+		c.maxMessage = size
+	}
+}
+
+// WithLevel sets the minimum level written.
+func WithLevel(level int) Option {
+	return func(c *config) {
+		c.level = level
+	}
+}
+
+// WithTimeLayout stamps messages using a time.Format layout, such as
+// time.RFC3339, instead of the log package's time flags. JSON output keeps
+// its own layout.
+func WithTimeLayout(layout string) Option {
+	return func(c *config) {
+		c.timeLayout = layout
+	}
+}
+
+// WithUTC stamps messages, and dates rotated file names, in UTC.
+func WithUTC(utc bool) Option {
+	return func(c *config) {
+		c.utc = utc
+		c.handler.RotateUTC = utc
+	}
+}
+
+// WithPrefix sets the exact prefix of each line, instead of the lowercased
+// name followed by ':'.
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = &prefix
+	}
+}
+
+// WithFileName sets the exact name of the log file, keeping its directory,
+// instead of the name GetLogger derives from the lowercased logger name.
+func WithFileName(name string) Option {
+	return func(c *config) {
+		c.handler.FilePath = filepath.Join(filepath.Dir(c.handler.FilePath), name)
+	}
+}
+
+// NewWithOptions creates a logger writing to fp, which without options is
+// never rotated.
+func NewWithOptions(name, fp string, opts ...Option) (*Vlogger, error) {
+	c := newConfig(fp, opts)
+	l, err := newLogger(name, c.handler.FilePath, c.mode, c.handler)
+	if err != nil {
+		return nil, err
+	}
+	c.apply(l)
+	return l, nil
+}
+
+// NewWriter creates a logger writing to w, with no file and no rotation.
+// Options about the file, such as WithMaxSize, have no effect.
+func NewWriter(name string, w io.Writer, opts ...Option) *Vlogger {
+	c := newConfig("", opts)
+	l := newVlogger(name, w)
+	l.HandleMode = RotateModeCustom
+	c.apply(l)
+	return l
+}
+
+func newConfig(fp string, opts []Option) *config {
+	c := &config{
+		handler: NewDefaultHandler(fp),
+		mode:    RotateModeCustom,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// apply sets the logger-level options on l.
+func (c *config) apply(l *Vlogger) {
+	l.SetLevel(c.level)
+	l.timeLayout = c.timeLayout
+	l.utc = c.utc
+	l.dedup = c.dedup
+	l.MaxMessageSize = c.maxMessage
+	if c.prefix != nil {
+		l.SetPrefix(*c.prefix)
+	}
+	if c.timeLayout != "" {
+		l.SetFlags(l.Flags() &^ (log.Ldate | log.Ltime | log.Lmicroseconds))
+	}
+	if c.utc {
+		l.SetFlags(l.Flags() | log.LUTC)
+	}
+}
@@ -0,0 +1,161 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// handlerOf returns the RotateHandler under l.
+func handlerOf(l *Vlogger) *RotateHandler {
+	return l.handler.(*RotateHandler)
+}
+
+func TestNewWithOptions(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "app.log")
+	l, err := NewWithOptions("app", fp,
+		WithMaxSize(1<<20), WithMaxDays(7), WithMaxBackups(5),
+		WithCompress(true), WithLevel(LevelWarn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	h := handlerOf(l)
+	if h.MaxSize != 1<<20 || h.MaxDays != 7 || h.MaxBackups != 5 || !h.Compress || !h.Rotatable {
+		t.Errorf("handler = %+v", h)
+	}
+	if l.GetLevel() != LevelWarn || l.HandleMode != RotateModeCustom || l.FilePath != fp {
+		t.Errorf("level %d, mode %d, path %s", l.GetLevel(), l.HandleMode, l.FilePath)
+	}
+}
+
+func TestNewWithOptionsConflicting(t *testing.T) {
+	dir := t.TempDir()
+	// the last option setting the same limit wins
+	l, err := NewWithOptions("app", filepath.Join(dir, "a.log"),
+		WithMaxSize(100), WithLevel(LevelError), WithMaxSize(200), WithLevel(LevelDebug))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if h := handlerOf(l); h.MaxSize != 200 || l.GetLevel() != LevelDebug {
+		t.Errorf("MaxSize %d, level %d, want the last ones", h.MaxSize, l.GetLevel())
+	}
+
+	// an option after a mode overrides its default
+	l2, err := NewWithOptions("app", filepath.Join(dir, "b.log"), withMode(RotateMode16M), WithMaxSize(300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l2.Close()
+	if h := handlerOf(l2); h.MaxSize != 300 || l2.HandleMode != RotateMode16M {
+		t.Errorf("MaxSize %d, mode %d", h.MaxSize, l2.HandleMode)
+	}
+}
+
+func TestNewModes(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		mode  int
+		check func(*RotateHandler) bool
+	}{
+		{RotateModeNoRotate, func(h *RotateHandler) bool { return !h.Rotatable }},
+		{RotateModeWeek, func(h *RotateHandler) bool { return h.MaxDays == 7 }},
+		{RotateModeMonth, func(h *RotateHandler) bool { return h.MaxDays == 30 }},
+		{RotateMode16M, func(h *RotateHandler) bool { return h.MaxSize == 1<<24 }},
+		{RotateMode256M, func(h *RotateHandler) bool { return h.MaxSize == 1<<28 }},
+		{RotateModeMillion, func(h *RotateHandler) bool { return h.MaxLines == 1000000 }},
+		{RotateModeHour, func(h *RotateHandler) bool { return h.MaxHours == 24 }},
+	} {
+		l := New("app", filepath.Join(dir, "app.log"), tt.mode)
+		if !tt.check(handlerOf(l)) || l.HandleMode != tt.mode {
+			t.Errorf("mode %d: handler %+v", tt.mode, handlerOf(l))
+		}
+		l.Close()
+	}
+}
+
+func TestTimeLayoutAndUTC(t *testing.T) {
+	instant := time.Date(2020, 3, 1, 23, 30, 0, 0, time.FixedZone("X", 2*3600))
+	m := message{level: LevelInfo, msg: "hi"}
+
+	local := NewWriter("app", ioutil.Discard, WithTimeLayout(time.RFC3339))
+	if got := string(local.formatText(nil, instant, m)); got != "app:2020-03-01T23:30:00+02:00 INFO: hi\n" {
+		t.Errorf("local line = %q", got)
+	}
+	utc := NewWriter("app", ioutil.Discard, WithTimeLayout(time.RFC3339), WithUTC(true))
+	// lines are stamped with now, which WithUTC puts in UTC
+	if loc := utc.now().Location(); loc != time.UTC {
+		t.Errorf("now is in %v, want UTC", loc)
+	}
+	if got := string(utc.formatText(nil, instant.UTC(), m)); got != "app:2020-03-01T21:30:00Z INFO: hi\n" {
+		t.Errorf("UTC line = %q", got)
+	}
+
+	// without a layout the log flags stamp the line, in UTC too
+	flags := NewWriter("app", ioutil.Discard, WithUTC(true))
+	flags.SetFlags(flags.Flags() | log.Ldate | log.Ltime)
+	if got := string(flags.formatText(nil, instant, m)); got != "app:2020/03/01 21:30:00.000000 INFO: hi\n" {
+		t.Errorf("flags line = %q", got)
+	}
+
+	l, err := NewWithOptions("app", filepath.Join(t.TempDir(), "app.log"), WithUTC(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if !handlerOf(l).RotateUTC {
+		t.Error("WithUTC does not date rotated files in UTC")
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("App", &buf, WithMaxSize(1), WithLevel(LevelWarn))
+	l.Info("dropped")
+	l.Warn("hello")
+	l.Warnf("x=%d", 1)
+
+	line := regexp.MustCompile(`^app:\d{2}:\d{2}:\d{2}\.\d{6} WARN: hello\napp:\d{2}:\d{2}:\d{2}\.\d{6} WARN: x=1\n$`)
+	if !line.MatchString(buf.String()) {
+		t.Errorf("output = %q", buf.String())
+	}
+	if l.FilePath != "" || l.HandleMode != RotateModeCustom || l.handler != nil {
+		t.Errorf("writer logger has file %q, mode %d, handler %v", l.FilePath, l.HandleMode, l.handler)
+	}
+	// no file to flush or close
+	l.Flush()
+	l.Close()
+}
+
+func TestPrefixAndFileNameVerbatim(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewWithOptions("MyApp", filepath.Join(dir, "ignored.log"),
+		WithPrefix("[MyApp] "), WithFileName("MyApp.Log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetFlags(0)
+	l.Info("hi")
+	l.Close()
+
+	fp := filepath.Join(dir, "MyApp.Log")
+	if l.FilePath != fp {
+		t.Errorf("FilePath = %q, want %q", l.FilePath, fp)
+	}
+	if got := readFile(t, fp); got != "[MyApp] INFO: hi\n" {
+		t.Errorf("file = %q", got)
+	}
+
+	// the defaults still change the case
+	var buf bytes.Buffer
+	def := NewWriter("MyApp", &buf)
+	if def.Prefix() != "myapp:" {
+		t.Errorf("default prefix = %q", def.Prefix())
+	}
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket capping writes at Rate lines per second,
+// with bursts of up to Burst lines, or a second's worth of lines if Burst is
+// not set. The bucket starts full. Lines over the limit are dropped, or
+// delayed until allowed when Block is set.
+type RateLimiter struct {
+	Rate  float64
+	Burst int
+	Block bool
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int, block bool) *RateLimiter {
+	return &RateLimiter{
+		Rate:  rate,
+		Burst: burst,
+		Block: block,
+	}
+}
+
+// burst returns the bucket size.
+func (r *RateLimiter) burst() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	if r.Rate > 1 {
+		return r.Rate
+	}
+	return 1
+}
+
+// wait takes a token, sleeping for one in blocking mode. It reports
+// whether the line may be written.
+func (r *RateLimiter) wait() bool {
+	d, ok := r.reserve(time.Now())
+	if d > 0 {
+		time.Sleep(d)
+	}
+	return ok
+}
+
+// reserve takes a token at now. In blocking mode the bucket may go into
+// debt, and the returned duration is how long until the token is earned.
+func (r *RateLimiter) reserve(now time.Time) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last.IsZero() {
+		r.tokens = r.burst()
+	} else {
+		r.tokens += now.Sub(r.last).Seconds() * r.Rate
+		if burst := r.burst(); r.tokens > burst {
+			r.tokens = burst
+		}
+	}
+	r.last = now
+	if r.tokens >= 1 {
+		r.tokens--
+		return 0, true
+	}
+	if !r.Block || r.Rate <= 0 {
+		return 0, false
+	}
+	r.tokens--
+	return time.Duration(-r.tokens / r.Rate * float64(time.Second)), true
+}
@@ -0,0 +1,75 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	start := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		name string
+		r    *RateLimiter
+		want int
+	}{
+		{"NewRateLimiter", NewRateLimiter(100, 10, false), 10},
+		{"literal", &RateLimiter{Rate: 100, Burst: 10}, 10},
+		{"no burst", &RateLimiter{Rate: 100}, 100},
+		{"slow, no burst", &RateLimiter{Rate: 0.5}, 1},
+	} {
+		allowed := 0
+		for i := 0; i < 1000; i++ {
+			if _, ok := c.r.reserve(start); ok {
+				allowed++
+			}
+		}
+		if allowed != c.want {
+			t.Errorf("%s: %d lines allowed in a burst, want %d", c.name, allowed, c.want)
+		}
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	r := NewRateLimiter(10, 5, false)
+	now := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	allowed := 0
+	// 5 at once, then 10 a second for 2 seconds
+	for i := 0; i < 2000; i++ {
+		if _, ok := r.reserve(now.Add(time.Duration(i) * time.Millisecond)); ok {
+			allowed++
+		}
+	}
+	if allowed < 24 || allowed > 25 {
+		t.Errorf("%d lines allowed over 2s, want 5 + 20", allowed)
+	}
+}
+
+func TestRateLimiterBlock(t *testing.T) {
+	r := NewRateLimiter(10, 1, true)
+	now := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	if d, ok := r.reserve(now); !ok || d != 0 {
+		t.Fatalf("first line: %s, %v", d, ok)
+	}
+	for i, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond} {
+		d, ok := r.reserve(now)
+		if !ok || d != want {
+			t.Errorf("line %d: waits %s, %v, want %s", i+2, d, ok, want)
+		}
+	}
+}
+
+func TestRateLimitWrites(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.RateLimit = NewRateLimiter(1, 50, false)
+	})
+	for i := 0; i < 200; i++ {
+		h.Write([]byte("x\n"))
+	}
+	h.Flush()
+	if got := countLinesIn(readFile(t, h.FilePath)); got != 50 {
+		t.Errorf("%d lines written, want the burst of 50", got)
+	}
+	if st := h.Stats(); st.Dropped != 150 {
+		t.Errorf("Dropped = %d, want 150", st.Dropped)
+	}
+}
@@ -0,0 +1,59 @@
+package log
+
+import (
+	"strings"
+	"sync"
+)
+
+// recentLines keeps the last lines written, see RotateHandler.RecentSize.
+type recentLines struct {
+	mu    sync.Mutex
+	lines []string
+	next  int // where the next line goes once lines is full
+}
+
+// add records data as the newest line, keeping at most size lines.
+func (r *recentLines) add(data []byte, size int) {
+	if size > 0 {
+		r.addString(string(data), size)
+	}
+}
+
+// addString is add for a string.
+func (r *recentLines) addString(s string, size int) {
+	if size <= 0 {
+		return
+	}
+	line := strings.TrimSuffix(s, "\n")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.lines) != size && r.next != 0 {
+		// RecentSize changed, unwrap the ring first
+		r.lines, r.next = r.ordered(), 0
+	}
+	if len(r.lines) > size {
+		r.lines = r.lines[len(r.lines)-size:]
+	}
+	if len(r.lines) < size {
+		r.lines = append(r.lines, line)
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % size
+}
+
+// ordered returns the lines oldest first. Must hold mu.
+func (r *recentLines) ordered() []string {
+	out := make([]string, 0, len(r.lines))
+	out = append(out, r.lines[r.next:]...)
+	return append(out, r.lines[:r.next]...)
+}
+
+// RecentLines returns the last RecentSize lines written, oldest first,
+// without their newlines. They are kept in memory whatever happens to the
+// files, for post-mortem debugging.
+func (w *RotateHandler) RecentLines() []string {
+	w.recent.mu.Lock()
+	defer w.recent.mu.Unlock()
+	return w.recent.ordered()
+}
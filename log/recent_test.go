@@ -0,0 +1,34 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRecentLines(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.RecentSize = 3
+	})
+	if got := h.RecentLines(); len(got) != 0 {
+		t.Errorf("RecentLines() = %q before any write", got)
+	}
+	for i := 1; i <= 7; i++ {
+		h.Write([]byte(fmt.Sprintf("line %d\n", i)))
+	}
+	if got := strings.Join(h.RecentLines(), ","); got != "line 5,line 6,line 7" {
+		t.Errorf("RecentLines() = %s", got)
+	}
+	h.WriteString("line 8\n")
+	h.WriteLines([][]byte{[]byte("line 9\n"), []byte("line 10\n")})
+	if got := strings.Join(h.RecentLines(), ","); got != "line 8,line 9,line 10" {
+		t.Errorf("RecentLines() = %s", got)
+	}
+
+	// shrinking keeps the newest
+	h.RecentSize = 2
+	h.Write([]byte("line 11\n"))
+	if got := strings.Join(h.RecentLines(), ","); got != "line 10,line 11" {
+		t.Errorf("RecentLines() after shrinking = %s", got)
+	}
+}
@@ -0,0 +1,122 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Reopen closes the log file and opens FilePath again, for when the file was
+// removed or moved away by something other than this handler.
+func (w *RotateHandler) Reopen() error {
+	w.startLock.Lock()
+	defer w.startLock.Unlock()
+	return w.reopen()
+}
+
+// Reset opens a closed handler's file again, with its counters and rotation
+// state cleared, as if it was just created and initialized. It fails if the
+// handler is open. Signals registered by RotateOnSignal are not restored.
+func (w *RotateHandler) Reset() error {
+	w.startLock.Lock()
+	if w.mw.logFile != nil {
+		w.startLock.Unlock()
+		return errors.New("reset: handler is open, Close it first")
+	}
+	w.stats.reset()
+	w.curLines, w.curSize = 0, 0
+	w.seqKey, w.lastSeq = "", 0
+	w.nextRotate, w.lastMoveCheck, w.suspendUntil = time.Time{}, time.Time{}, time.Time{}
+	err := w.openLogFile()
+	if err == nil {
+		w.rotateOnOpen()
+	}
+	w.startLock.Unlock()
+	if err != nil {
+		return fmt.Errorf("reset: %s", err)
+	}
+	w.startSyncLoop()
+	return nil
+}
+
+// SetFilePath moves the handler to newPath: the current file is flushed and
+// closed, and later writes go to newPath, which is created if needed. On
+// failure the handler keeps writing to its current file.
+func (w *RotateHandler) SetFilePath(newPath string) error {
+	w.startLock.Lock()
+	defer w.startLock.Unlock()
+	w.mw.Lock()
+	defer w.mw.Unlock()
+	w.mw.flush()
+	old := w.FilePath
+	w.FilePath = newPath
+	if err := w.openLogFile(); err != nil {
+		w.FilePath = old
+		return fmt.Errorf("set file path: %s", err)
+	}
+	w.seqKey, w.lastSeq = "", 0
+	return nil
+}
+
+// SetFilePath moves the logger's file to newPath, see
+// RotateHandler.SetFilePath. It fails for loggers not writing to a file.
+func (l *Vlogger) SetFilePath(newPath string) error {
+	h, ok := l.handler.(*RotateHandler)
+	if !ok {
+		return errors.New("set file path: logger has no RotateHandler")
+	}
+	if err := h.SetFilePath(newPath); err != nil {
+		return err
+	}
+	l.FilePath = newPath
+	return nil
+}
+
+// File returns the open log file, or nil if the handler is not open, for
+// fcntl locks, fadvise and the like. It is only valid until the next
+// rotation or reopen, which close it, and writing to it directly bypasses
+// the handler's counters and buffer.
+func (w *RotateHandler) File() *os.File {
+	w.mw.Lock()
+	defer w.mw.Unlock()
+	return w.mw.logFile
+}
+
+// reopen is Reopen for callers holding startLock.
+func (w *RotateHandler) reopen() error {
+	w.mw.Lock()
+	defer w.mw.Unlock()
+	w.mw.flush()
+	if err := w.openLogFile(); err != nil {
+		return fmt.Errorf("reopen: %s", err)
+	}
+	return nil
+}
+
+// fileMoved reports whether FilePath no longer names the open file.
+func (w *RotateHandler) fileMoved() bool {
+	if w.mw.logFile == nil {
+		return false
+	}
+	cur, err := w.mw.logFile.Stat()
+	if err != nil {
+		return true
+	}
+	fi, err := os.Stat(w.FilePath)
+	return err != nil || !os.SameFile(fi, cur)
+}
+
+// checkMoved reopens the file if it was moved, checking at most once every
+// ReopenInterval. Must hold startLock.
+func (w *RotateHandler) checkMoved(now time.Time) {
+	if w.ReopenInterval <= 0 || now.Sub(w.lastMoveCheck) < w.ReopenInterval {
+		return
+	}
+	w.lastMoveCheck = now
+	if w.fileMoved() {
+		if err := w.reopen(); err != nil {
+			w.reportError(err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReopenAfterDelete(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.ReopenInterval = time.Nanosecond
+	})
+	h.Write([]byte("lost\n"))
+	if err := os.Remove(h.FilePath); err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("found\n"))
+	if got := readFile(t, h.FilePath); got != "found\n" {
+		t.Errorf("file = %q, want a fresh file", got)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	h := newTestHandler(t, nil)
+	h.Write([]byte("old\n"))
+	moved := h.FilePath + ".moved"
+	if err := os.Rename(h.FilePath, moved); err != nil {
+		t.Fatal(err)
+	}
+	// without ReopenInterval writes follow the moved file
+	h.Write([]byte("still old\n"))
+	if err := h.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("new\n"))
+	if got := readFile(t, moved); got != "old\nstill old\n" {
+		t.Errorf("moved file = %q", got)
+	}
+	if got := readFile(t, h.FilePath); got != "new\n" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestSetFilePathDuringCleanup(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxSize = 10
+		h.Rotatable = true
+		h.MaxBackups = 2
+	})
+	dir := filepath.Dir(h.FilePath)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if err := h.SetFilePath(filepath.Join(dir, fmt.Sprintf("moved%d.log", i%2))); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		h.Write([]byte("1234567\n"))
+	}
+	<-done
+	h.Close()
+
+	for _, name := range []string{"moved0.log", "moved1.log"} {
+		m, _ := filepath.Glob(filepath.Join(dir, name+".*"))
+		if len(m) > 2 {
+			t.Errorf("%s has %d archives, want at most 2", name, len(m))
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	h := newTestHandler(t, nil)
+	if err := h.Reset(); err == nil {
+		t.Error("Reset of an open handler succeeded")
+	}
+	h.Write([]byte("before\n"))
+	h.Close()
+	if _, err := h.Write([]byte("closed\n")); err != ErrWriterNotInitialized {
+		t.Errorf("Write after Close = %v, want ErrWriterNotInitialized", err)
+	}
+
+	if err := h.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if st := h.Stats(); st != (Stats{}) {
+		t.Errorf("Stats after Reset = %+v, want zero", st)
+	}
+	h.Write([]byte("after\n"))
+	if got := readFile(t, h.FilePath); got != "before\nafter\n" {
+		t.Errorf("file = %q", got)
+	}
+	if n := h.CurrentLines(); n != 2 {
+		t.Errorf("CurrentLines = %d, want 2", n)
+	}
+}
+
+func TestFile(t *testing.T) {
+	h := newTestHandler(t, nil)
+	same := func(f *os.File) bool {
+		cur, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(h.FilePath)
+		return err == nil && os.SameFile(fi, cur)
+	}
+	f := h.File()
+	if f == nil || !same(f) {
+		t.Fatalf("File() = %v, not the file at %s", f, h.FilePath)
+	}
+	h.Write([]byte("line\n"))
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	// the old file was rotated away and closed
+	if h.File() == f || !same(h.File()) {
+		t.Error("File() does not follow the rotation")
+	}
+	h.Close()
+	if h.File() != nil {
+		t.Error("File() after Close is not nil")
+	}
+}
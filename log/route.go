@@ -0,0 +1,72 @@
+package log
+
+// LevelRouter writes every line to a primary handler and also copies lines
+// at or above Threshold to a secondary one, e.g. app.log rotating daily and
+// app.error.log rotating by size. Lines written without a level, see
+// LevelHandler, only go to the primary handler.
+type LevelRouter struct {
+	primary   *RotateHandler
+	secondary *RotateHandler
+	Threshold int
+}
+
+// NewLevelRouter routes between two handlers, each keeping its own rotation
+// policy. Init, Flush and Close apply to both.
+func NewLevelRouter(primary *RotateHandler, threshold int, secondary *RotateHandler) *LevelRouter {
+	return &LevelRouter{
+		primary:   primary,
+		secondary: secondary,
+		Threshold: threshold,
+	}
+}
+
+// Write writes data to the primary handler only.
+func (h *LevelRouter) Write(data []byte) (int, error) {
+	return h.primary.Write(data)
+}
+
+// WriteLevel writes data to the primary handler and, if level is high
+// enough, the secondary one, returning the first error.
+func (h *LevelRouter) WriteLevel(level int, data []byte) (int, error) {
+	_, err := h.primary.Write(data)
+	if level >= h.Threshold {
+		if _, serr := h.secondary.Write(data); err == nil {
+			err = serr
+		}
+	}
+	return len(data), err
+}
+
+// Init opens both files, panicking on failure. See InitE.
+func (h *LevelRouter) Init() {
+	if err := h.InitE(); err != nil {
+		panic(err)
+	}
+}
+
+// InitE opens both files.
+func (h *LevelRouter) InitE() error {
+	if err := h.primary.InitE(); err != nil {
+		return err
+	}
+	if err := h.secondary.InitE(); err != nil {
+		h.primary.Close()
+		return err
+	}
+	return nil
+}
+
+func (h *LevelRouter) Flush() {
+	h.primary.Flush()
+	h.secondary.Flush()
+}
+
+func (h *LevelRouter) Close() {
+	h.primary.Close()
+	h.secondary.Close()
+}
+
+// activeFile reports the primary handler's file.
+func (h *LevelRouter) activeFile() (string, int) {
+	return h.primary.activeFile()
+}
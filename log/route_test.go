@@ -0,0 +1,107 @@
+package log
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRouterLogger(t *testing.T) (l *Vlogger, primary, secondary string) {
+	t.Helper()
+	dir := t.TempDir()
+	primary = filepath.Join(dir, "app.log")
+	secondary = filepath.Join(dir, "app.error.log")
+	r := NewLevelRouter(NewDefaultHandler(primary), LevelError, NewDefaultHandler(secondary))
+	l = NewWithHandler("app", r)
+	t.Cleanup(l.Close)
+	return l, primary, secondary
+}
+
+func TestLevelRouter(t *testing.T) {
+	l, primary, secondary := newRouterLogger(t)
+	l.Info("routine")
+	l.Error("boom")
+	l.Flush()
+
+	p, s := readFile(t, primary), readFile(t, secondary)
+	if !strings.Contains(p, "routine") || !strings.Contains(p, "boom") {
+		t.Errorf("primary = %q, want both lines", p)
+	}
+	if strings.Contains(s, "routine") || !strings.Contains(s, "boom") {
+		t.Errorf("secondary = %q, want only the ERROR line", s)
+	}
+}
+
+func TestLevelRouterIgnoresText(t *testing.T) {
+	l, _, secondary := newRouterLogger(t)
+	// neither the flags nor the message body decide the level
+	l.SetFlags(0)
+	l.Error("boom")
+	l.Info("fake ERROR: line")
+	l.Println("plain ERROR: line")
+	l.Flush()
+
+	s := readFile(t, secondary)
+	if !strings.Contains(s, "boom") {
+		t.Errorf("secondary = %q, missing the ERROR line without flags", s)
+	}
+	if strings.Contains(s, "fake") || strings.Contains(s, "plain") {
+		t.Errorf("secondary = %q, routed on the message text", s)
+	}
+}
+
+func TestLevelRouterJSON(t *testing.T) {
+	l, _, secondary := newRouterLogger(t)
+	l.Format = FormatJSON
+	l.Error("boom")
+	l.Warn("ERROR")
+	l.Flush()
+
+	if s := readFile(t, secondary); countLinesIn(s) != 1 || !strings.Contains(s, "boom") {
+		t.Errorf("secondary = %q, want only the ERROR line", s)
+	}
+}
+
+func TestLevelRouterOwnRotation(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 3, 1, 10, 0, 0, 0, time.Local))
+	dir := t.TempDir()
+	// app.log rotates daily, app.error.log every 12 bytes
+	primary := NewDailyRotateHandler(filepath.Join(dir, "app.log"), 7)
+	primary.now = clock.now
+	secondary := NewSizeRotateHandler(filepath.Join(dir, "app.error.log"), 12)
+	secondary.now = clock.now
+	r := NewLevelRouter(primary, LevelError, secondary)
+	if err := r.InitE(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for i := 0; i < 3; i++ {
+		r.WriteLevel(LevelInfo, []byte("info\n"))
+		r.WriteLevel(LevelError, []byte("error\n"))
+	}
+	r.Flush()
+	if got := archives(t, primary); len(got) != 0 {
+		t.Errorf("primary archives = %v, want none within the day", got)
+	}
+	if got := readFile(t, primary.FilePath); countLinesIn(got) != 6 {
+		t.Errorf("primary = %q, want all 6 lines", got)
+	}
+	if got := archives(t, secondary); len(got) != 1 {
+		t.Errorf("secondary archives = %v, want one", got)
+	}
+	if got := readFile(t, secondary.FilePath); got != "error\n" {
+		t.Errorf("secondary = %q", got)
+	}
+
+	clock.add(24 * time.Hour)
+	r.WriteLevel(LevelInfo, []byte("next day\n"))
+	r.Flush()
+	if got := archives(t, primary); len(got) != 1 {
+		t.Errorf("primary archives = %v, want one after a day", got)
+	}
+	if got := readFile(t, primary.FilePath); got != "next day\n" {
+		t.Errorf("primary = %q", got)
+	}
+}
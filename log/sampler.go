@@ -0,0 +1,49 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler limits repeated messages. Within each Tick, the first First
+// messages with the same level and text are written, then only every
+// Thereafter-th one; Thereafter 0 drops the rest of the tick.
+type Sampler struct {
+	Tick       time.Duration
+	First      int
+	Thereafter int
+
+	mu        sync.Mutex
+	windowEnd time.Time
+	counts    map[sampleKey]int
+}
+
+type sampleKey struct {
+	level int
+	msg   string
+}
+
+func NewSampler(tick time.Duration, first, thereafter int) *Sampler {
+	return &Sampler{
+		Tick:       tick,
+		First:      first,
+		Thereafter: thereafter,
+	}
+}
+
+// allow counts a message and reports whether it should be written.
+func (s *Sampler) allow(level int, msg string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil || !now.Before(s.windowEnd) {
+		s.counts = make(map[sampleKey]int)
+		s.windowEnd = now.Add(s.Tick)
+	}
+	key := sampleKey{level, msg}
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.First {
+		return true
+	}
+	return s.Thereafter > 0 && (n-s.First)%s.Thereafter == 0
+}
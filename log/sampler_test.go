@@ -0,0 +1,64 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSamplerArithmetic(t *testing.T) {
+	start := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSampler(time.Second, 3, 5)
+	allowed := 0
+	for i := 0; i < 23; i++ {
+		if s.allow(LevelInfo, "same", start) {
+			allowed++
+		}
+	}
+	// 3 first, then the 8th, 13th, 18th and 23rd
+	if allowed != 7 {
+		t.Errorf("%d of 23 allowed, want 7", allowed)
+	}
+	// counted apart: another message, and the same one at another level
+	if !s.allow(LevelInfo, "other", start) || !s.allow(LevelError, "same", start) {
+		t.Error("a different key was sampled with the first")
+	}
+	// a new tick starts counting again
+	next := start.Add(time.Second)
+	for i := 0; i < 3; i++ {
+		if !s.allow(LevelInfo, "same", next) {
+			t.Errorf("message %d of the new tick dropped", i+1)
+		}
+	}
+	if s.allow(LevelInfo, "same", next) {
+		t.Error("4th message of the new tick allowed")
+	}
+}
+
+func TestSamplerDropsRest(t *testing.T) {
+	start := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSampler(time.Minute, 2, 0)
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		if s.allow(LevelWarn, "flood", start.Add(time.Duration(i)*time.Millisecond)) {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("%d allowed, want 2", allowed)
+	}
+}
+
+func TestSamplerCountsDrops(t *testing.T) {
+	h := newTestHandler(t, nil)
+	l := NewWithHandler("app", h)
+	l.Sampler = NewSampler(time.Hour, 1, 0)
+	for i := 0; i < 5; i++ {
+		l.Info("repeated")
+	}
+	if got := countLinesIn(readFile(t, h.FilePath)); got != 1 {
+		t.Errorf("%d lines written, want 1", got)
+	}
+	if d := h.Stats().Dropped; d != 4 {
+		t.Errorf("Stats().Dropped = %d, want 4", d)
+	}
+}
@@ -0,0 +1,48 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync/atomic"
+)
+
+// RotateOnSignal rotates the log file each time sig is received, for use with
+// external tools such as logrotate that send SIGHUP. If the file was already
+// moved away it is reopened instead. It stops on Close.
+func (w *RotateHandler) RotateOnSignal(sig os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig)
+
+	w.startLock.Lock()
+	w.signals = append(w.signals, ch)
+	w.startLock.Unlock()
+
+	go func() {
+		for range ch {
+			gen := atomic.LoadUint32(&w.rotations)
+			w.startLock.Lock()
+			var err error
+			if w.fileMoved() {
+				// already moved away by logrotate, just reopen
+				err = w.reopen()
+			} else {
+				err = w.rotateSince(gen)
+			}
+			if err != nil {
+				w.reportError(err)
+			}
+			w.startLock.Unlock()
+		}
+	}()
+}
+
+// stopSignals stops rotating on the signals registered by RotateOnSignal.
+func (w *RotateHandler) stopSignals() {
+	w.startLock.Lock()
+	defer w.startLock.Unlock()
+	for _, ch := range w.signals {
+		signal.Stop(ch)
+		close(ch)
+	}
+	w.signals = nil
+}
@@ -0,0 +1,35 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRotateOnSignal(t *testing.T) {
+	h := newTestHandler(t, nil)
+	h.RotateOnSignal(syscall.SIGHUP)
+	h.Write([]byte("before\n"))
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(archives(t, h)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no rotation after SIGHUP")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	h.Write([]byte("after\n"))
+	got := archives(t, h)
+	if len(got) != 1 || readFile(t, got[0]) != "before\n" {
+		t.Fatalf("archives = %v", got)
+	}
+	if got := readFile(t, h.FilePath); got != "after\n" {
+		t.Errorf("file = %q, want a new file", got)
+	}
+}
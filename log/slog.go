@@ -0,0 +1,84 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler is a slog.Handler writing through a Vlogger, in its format and
+// subject to its level. Attributes in groups get keys like "group.key".
+type SlogHandler struct {
+	logger *Vlogger
+	attrs  Fields
+	prefix string // open groups, as "a.b."
+}
+
+// NewSlogHandler returns a handler for slog.New(NewSlogHandler(l)).
+func NewSlogHandler(l *Vlogger) *SlogHandler {
+	return &SlogHandler{logger: l}
+}
+
+// fromSlogLevel maps a slog level onto the nearest level at or below it.
+func fromSlogLevel(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.enabled(fromSlogLevel(level))
+}
+
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := mergeFields(h.attrs, nil)
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(fields, h.prefix, a)
+		return true
+	})
+	// the record knows its caller, the stack here is slog's
+	h.logger.outputAt(fromSlogLevel(r.Level), r.Message, fields, r.PC)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := mergeFields(h.attrs, nil)
+	for _, a := range attrs {
+		addAttr(fields, h.prefix, a)
+	}
+	return &SlogHandler{logger: h.logger, attrs: fields, prefix: h.prefix}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{logger: h.logger, attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// addAttr adds a to fields under prefix, flattening groups into dotted keys.
+func addAttr(fields Fields, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addAttr(fields, prefix, ga)
+		}
+		return
+	}
+	fields[prefix+a.Key] = a.Value.Any()
+}
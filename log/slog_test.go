@@ -0,0 +1,87 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"runtime"
+	"testing"
+)
+
+func TestSlogAttrsAndGroups(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	s := slog.New(NewSlogHandler(l)).With("svc", "api").WithGroup("req")
+	s.Info("served", "path", "/a b", slog.Group("user", "id", 7), slog.Group("", "inline", true))
+	s.Debug("dropped below the level")
+
+	want := "app:INFO: served req.inline=true req.path=\"/a b\" req.user.id=7 svc=api\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestSlogJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.Format = FormatJSON
+	slog.New(NewSlogHandler(l)).WithGroup("g").With("a", 1).Warn("careful", "b", "x")
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal %q: %s", buf.String(), err)
+	}
+	if got["level"] != "WARN" || got["msg"] != "careful" || got["g.a"] != float64(1) || got["g.b"] != "x" {
+		t.Errorf("line = %v", got)
+	}
+}
+
+func TestSlogLevels(t *testing.T) {
+	for level, want := range map[slog.Level]int{
+		slog.LevelDebug:     LevelDebug,
+		slog.LevelInfo:      LevelInfo,
+		slog.LevelInfo + 2:  LevelInfo,
+		slog.LevelWarn:      LevelWarn,
+		slog.LevelError:     LevelError,
+		slog.LevelError + 4: LevelError,
+	} {
+		if got := fromSlogLevel(level); got != want {
+			t.Errorf("fromSlogLevel(%v) = %d, want %d", level, got, want)
+		}
+	}
+}
+
+func TestSlogCaller(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	l.Caller = true
+	s := slog.New(NewSlogHandler(l))
+	_, _, line, _ := runtime.Caller(0)
+	s.Info("hello")
+
+	want := fmt.Sprintf("app:slog_test.go:%d: INFO: hello\n", line+1)
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestSlogShortfile(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(log.Lshortfile)
+	s := slog.New(NewSlogHandler(l))
+	_, _, line, _ := runtime.Caller(0)
+	s.Warn("careful")
+
+	want := fmt.Sprintf("app:slog_test.go:%d: WARN: careful\n", line+1)
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
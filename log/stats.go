@@ -0,0 +1,77 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats are a RotateHandler's cumulative counters, which unlike the current
+// file's size and line count carry over across rotations.
+type Stats struct {
+	Bytes        int64
+	Lines        int64
+	Rotations    int64
+	LastRotation time.Time // zero if the handler never rotated
+	// Dropped counts messages discarded before reaching the file
+	Dropped int64
+}
+
+// handlerStats holds the counters behind Stats, updated atomically.
+type handlerStats struct {
+	bytes        int64
+	lines        int64
+	rotations    int64
+	lastRotation int64 // unix nanoseconds
+	dropped      int64
+}
+
+func (s *handlerStats) wrote(n, lines int) {
+	atomic.AddInt64(&s.bytes, int64(n))
+	atomic.AddInt64(&s.lines, int64(lines))
+}
+
+func (s *handlerStats) drop() {
+	atomic.AddInt64(&s.dropped, 1)
+}
+
+func (s *handlerStats) rotated(t time.Time) {
+	atomic.AddInt64(&s.rotations, 1)
+	atomic.StoreInt64(&s.lastRotation, t.UnixNano())
+}
+
+func (s *handlerStats) reset() {
+	atomic.StoreInt64(&s.bytes, 0)
+	atomic.StoreInt64(&s.lines, 0)
+	atomic.StoreInt64(&s.rotations, 0)
+	atomic.StoreInt64(&s.lastRotation, 0)
+	atomic.StoreInt64(&s.dropped, 0)
+}
+
+// Stats returns the handler's write and rotation counters.
+func (w *RotateHandler) Stats() Stats {
+	st := Stats{
+		Bytes:     atomic.LoadInt64(&w.stats.bytes),
+		Lines:     atomic.LoadInt64(&w.stats.lines),
+		Rotations: atomic.LoadInt64(&w.stats.rotations),
+		Dropped:   atomic.LoadInt64(&w.stats.dropped),
+	}
+	if t := atomic.LoadInt64(&w.stats.lastRotation); t != 0 {
+		st.LastRotation = time.Unix(0, t)
+	}
+	return st
+}
+
+// CurrentSize returns the size in bytes of the active file, counting what
+// is still buffered.
+func (w *RotateHandler) CurrentSize() int {
+	w.startLock.Lock()
+	defer w.startLock.Unlock()
+	return w.curSize
+}
+
+// CurrentLines returns the number of lines in the active file.
+func (w *RotateHandler) CurrentLines() int {
+	w.startLock.Lock()
+	defer w.startLock.Unlock()
+	return w.curLines
+}
@@ -0,0 +1,61 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 3, 1, 10, 0, 0, 0, time.Local))
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxLines = 3
+		h.Rotatable = true
+		h.now = clock.now
+	})
+	if st := h.Stats(); st != (Stats{}) {
+		t.Errorf("new handler Stats = %+v", st)
+	}
+	for i := 0; i < 7; i++ {
+		clock.add(time.Minute)
+		h.Write([]byte("line\n"))
+	}
+
+	st := h.Stats()
+	want := Stats{
+		Bytes:        35,
+		Lines:        7,
+		Rotations:    2,
+		LastRotation: time.Date(2020, 3, 1, 10, 7, 0, 0, time.Local),
+	}
+	if st.Bytes != want.Bytes || st.Lines != want.Lines || st.Rotations != want.Rotations ||
+		!st.LastRotation.Equal(want.LastRotation) {
+		t.Errorf("Stats = %+v, want %+v", st, want)
+	}
+	// the per-file count starts again with each file
+	if n := h.CurrentLines(); n != 1 {
+		t.Errorf("CurrentLines = %d, want 1", n)
+	}
+}
+
+func TestCurrentSizeAndLines(t *testing.T) {
+	h := newTestHandler(t, nil)
+	h.Write([]byte("one\n"))
+	h.Write([]byte("two\n"))
+	h.Write([]byte("three\n"))
+	if got := h.CurrentSize(); got != 14 {
+		t.Errorf("CurrentSize() = %d, want 14", got)
+	}
+	if got := h.CurrentLines(); got != 3 {
+		t.Errorf("CurrentLines() = %d, want 3", got)
+	}
+
+	if err := h.DoRotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.CurrentSize(); got != 0 {
+		t.Errorf("CurrentSize() after rotation = %d, want 0", got)
+	}
+	if got := h.CurrentLines(); got != 0 {
+		t.Errorf("CurrentLines() after rotation = %d, want 0", got)
+	}
+}
@@ -0,0 +1,67 @@
+package log
+
+import "sync"
+
+// subscriberBuffer is how many lines a subscriber may fall behind before
+// further lines are dropped for it.
+const subscriberBuffer = 256
+
+// subscribers fans written lines out to Subscribe channels.
+type subscribers struct {
+	mu   sync.Mutex
+	subs map[chan []byte]struct{}
+}
+
+// Subscribe returns a channel receiving a copy of every line written from now
+// on, e.g. to stream the log over HTTP. A subscriber that falls behind misses
+// lines rather than slowing down writers. cancel closes the channel.
+func (w *RotateHandler) Subscribe() (<-chan []byte, func()) {
+	ch := make(chan []byte, subscriberBuffer)
+	s := &w.subscribers
+	s.mu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[chan []byte]struct{})
+	}
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, ch)
+			s.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+func (s *subscribers) publish(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.subs) == 0 {
+		return
+	}
+	s.send(append([]byte(nil), data...))
+}
+
+// publishString is publish for a string.
+func (s *subscribers) publishString(data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.subs) == 0 {
+		return
+	}
+	s.send([]byte(data))
+}
+
+// send offers line to each subscriber. Must hold mu.
+func (s *subscribers) send(line []byte) {
+	for ch := range s.subs {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
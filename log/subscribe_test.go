@@ -0,0 +1,43 @@
+package log
+
+import "testing"
+
+func TestSubscribe(t *testing.T) {
+	h := newTestHandler(t, nil)
+	h.Write([]byte("before\n"))
+	ch, cancel := h.Subscribe()
+	h.Write([]byte("one\n"))
+	h.WriteString("two\n")
+	h.WriteLines([][]byte{[]byte("three\n"), []byte("four\n")})
+
+	for _, want := range []string{"one\n", "two\n", "three\n", "four\n"} {
+		if got := string(<-ch); got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	}
+	cancel()
+	h.Write([]byte("after\n"))
+	if line, ok := <-ch; ok {
+		t.Errorf("received %q after cancel", line)
+	}
+	cancel() // a second cancel is harmless
+	if n := len(h.subscribers.subs); n != 0 {
+		t.Errorf("%d subscribers left", n)
+	}
+}
+
+func TestSubscribeSlowConsumer(t *testing.T) {
+	h := newTestHandler(t, nil)
+	ch, cancel := h.Subscribe()
+	defer cancel()
+	for i := 0; i < subscriberBuffer+10; i++ {
+		h.Write([]byte("line\n"))
+	}
+	// writes went on, the lines past the buffer were dropped
+	if n := len(ch); n != subscriberBuffer {
+		t.Errorf("%d lines buffered, want %d", n, subscriberBuffer)
+	}
+	if got := countLinesIn(readFile(t, h.FilePath)); got != subscriberBuffer+10 {
+		t.Errorf("%d lines written", got)
+	}
+}
@@ -0,0 +1,33 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// updateSymlink points w.Symlink at the active log file. The link is swapped
+// in with a rename, so readers never see it missing.
+func (w *RotateHandler) updateSymlink() error {
+	if w.Symlink == "" {
+		return nil
+	}
+	if fi, err := os.Lstat(w.Symlink); err == nil && fi.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("symlink: %s exists and is not a symlink", w.Symlink)
+	}
+
+	target, err := filepath.Abs(w.FilePath)
+	if err != nil {
+		return fmt.Errorf("symlink: %s", err)
+	}
+	tmp := w.Symlink + ".tmp"
+	os.Remove(tmp)
+	if err = os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("symlink: %s", err)
+	}
+	if err = os.Rename(tmp, w.Symlink); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("symlink: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSymlinkFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "current")
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.Symlink = link
+	})
+	for i := 0; i < 2; i++ {
+		h.Write([]byte("line\n"))
+		if err := h.DoRotate(); err != nil {
+			t.Fatal(err)
+		}
+		target, err := os.Readlink(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, _ := filepath.Abs(h.FilePath); target != want {
+			t.Errorf("link -> %s, want %s", target, want)
+		}
+		h.Write([]byte("new\n"))
+		if got := readFile(t, link); got != "new\n" {
+			t.Errorf("reading the link = %q, want the active file", got)
+		}
+	}
+}
+
+func TestSymlinkOverRegularFile(t *testing.T) {
+	link := filepath.Join(t.TempDir(), "current")
+	if err := ioutil.WriteFile(link, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := NewDefaultHandler(filepath.Join(t.TempDir(), "test.log"))
+	h.Symlink = link
+	err := h.InitE()
+	h.Close()
+	if err == nil {
+		t.Fatal("no error replacing a regular file")
+	}
+	if got := readFile(t, link); got != "keep" {
+		t.Errorf("regular file changed to %q", got)
+	}
+}
+
+// blockSymlink replaces h's symlink with a regular file, so updating it fails.
+func blockSymlink(t *testing.T, h *RotateHandler) {
+	t.Helper()
+	if err := os.Remove(h.Symlink); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(h.Symlink, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetFilePathSymlinkError(t *testing.T) {
+	dir := t.TempDir()
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.Symlink = filepath.Join(dir, "current")
+	})
+	old := h.FilePath
+	h.Write([]byte("before\n"))
+	blockSymlink(t, h)
+
+	newPath := filepath.Join(dir, "new.log")
+	if err := h.SetFilePath(newPath); err == nil {
+		t.Fatal("SetFilePath succeeded with a regular file at Symlink")
+	}
+	if h.FilePath != old {
+		t.Errorf("FilePath = %s, want %s", h.FilePath, old)
+	}
+	h.Write([]byte("after\n"))
+	if got := readFile(t, old); got != "before\nafter\n" {
+		t.Errorf("file = %q, want writes to stay on it", got)
+	}
+	if got, _ := ioutil.ReadFile(newPath); len(got) != 0 {
+		t.Errorf("new file = %q, want nothing written", got)
+	}
+	if got := h.CurrentLines(); got != 2 {
+		t.Errorf("CurrentLines = %d, want 2", got)
+	}
+}
+
+func TestRotateSymlinkError(t *testing.T) {
+	dir := t.TempDir()
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.Symlink = filepath.Join(dir, "current")
+	})
+	h.Write([]byte("before\n"))
+	blockSymlink(t, h)
+
+	var re *RotateError
+	if err := h.DoRotate(); !errors.As(err, &re) || re.Op != "open" {
+		t.Fatalf("DoRotate = %v, want an open error", err)
+	}
+	if got := archives(t, h); len(got) != 0 {
+		t.Errorf("archives = %v, want the file moved back", got)
+	}
+	if _, err := h.Write([]byte("after\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, h.FilePath); got != "before\nafter\n" {
+		t.Errorf("file = %q, want writes to go on", got)
+	}
+}
@@ -0,0 +1,14 @@
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+// fdatasync syncs f's data, and only the metadata needed to read it back.
+func fdatasync(f *os.File) error {
+	if err := syscall.Fdatasync(int(f.Fd())); err != nil {
+		return &os.PathError{Op: "fdatasync", Path: f.Name(), Err: err}
+	}
+	return nil
+}
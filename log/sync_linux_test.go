@@ -0,0 +1,37 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestFlushDataSync(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.FlushMode = FlushDataSync
+	})
+	h.Write([]byte("durable\n"))
+	if err := h.mw.syncMode(h.FlushMode); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, h.FilePath); got != "durable\n" {
+		t.Errorf("file = %q", got)
+	}
+
+	// a closed file tells the two paths apart by the failing call
+	f, err := os.Open(h.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	h.mw.Lock()
+	h.mw.SetLogFile(f)
+	h.mw.Unlock()
+	var pe *os.PathError
+	if err := h.mw.syncMode(FlushDataSync); !errors.As(err, &pe) || pe.Op != "fdatasync" {
+		t.Errorf("FlushDataSync = %v, want an fdatasync error", err)
+	}
+	if err := h.mw.syncMode(FlushSync); !errors.As(err, &pe) || pe.Op != "sync" {
+		t.Errorf("FlushSync = %v, want a sync error", err)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package log
+
+import "os"
+
+// fdatasync falls back to a full sync where there is no fdatasync.
+func fdatasync(f *os.File) error {
+	return f.Sync()
+}
@@ -0,0 +1,110 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"log/syslog"
+	"strings"
+	"sync"
+)
+
+// SyslogHandler sends each line to a syslog daemon at the priority matching
+// its level, redialing once if the connection was lost.
+type SyslogHandler struct {
+	Network string
+	Raddr   string
+	Tag     string
+
+	mu sync.Mutex
+	w  *syslog.Writer
+}
+
+// NewSyslogHandler logs to the syslog daemon at raddr over network, or to
+// the local daemon when both are empty.
+func NewSyslogHandler(network, raddr, tag string) *SyslogHandler {
+	return &SyslogHandler{
+		Network: network,
+		Raddr:   raddr,
+		Tag:     tag,
+	}
+}
+
+func (h *SyslogHandler) Init() {
+	if err := h.InitE(); err != nil {
+		panic(err)
+	}
+}
+
+func (h *SyslogHandler) InitE() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.dial()
+}
+
+func (h *SyslogHandler) dial() error {
+	if h.w != nil {
+		h.w.Close()
+		h.w = nil
+	}
+	w, err := syslog.Dial(h.Network, h.Raddr, syslog.LOG_INFO|syslog.LOG_USER, h.Tag)
+	if err != nil {
+		return err
+	}
+	h.w = w
+	return nil
+}
+
+// Write sends data at LevelInfo.
+func (h *SyslogHandler) Write(data []byte) (int, error) {
+	return h.WriteLevel(LevelInfo, data)
+}
+
+// WriteLevel sends data at the priority matching level.
+func (h *SyslogHandler) WriteLevel(level int, data []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	msg := strings.TrimSuffix(string(data), "\n")
+	err := h.send(level, msg)
+	if err != nil {
+		// the daemon may have restarted, redial and retry once
+		if err = h.dial(); err == nil {
+			err = h.send(level, msg)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (h *SyslogHandler) send(level int, msg string) error {
+	if h.w == nil {
+		if err := h.dial(); err != nil {
+			return err
+		}
+	}
+	switch level {
+	case LevelDebug:
+		return h.w.Debug(msg)
+	case LevelWarn:
+		return h.w.Warning(msg)
+	case LevelError:
+		return h.w.Err(msg)
+	case LevelFatal:
+		return h.w.Crit(msg)
+	default:
+		return h.w.Info(msg)
+	}
+}
+
+func (h *SyslogHandler) Flush() {}
+
+func (h *SyslogHandler) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.w != nil {
+		h.w.Close()
+		h.w = nil
+	}
+}
@@ -0,0 +1,31 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package log
+
+// SyslogHandler discards everything on platforms without syslog.
+type SyslogHandler struct {
+	Network string
+	Raddr   string
+	Tag     string
+}
+
+func NewSyslogHandler(network, raddr, tag string) *SyslogHandler {
+	return &SyslogHandler{
+		Network: network,
+		Raddr:   raddr,
+		Tag:     tag,
+	}
+}
+
+func (h *SyslogHandler) Init() {}
+
+func (h *SyslogHandler) InitE() error { return nil }
+
+func (h *SyslogHandler) Write(data []byte) (int, error) { return len(data), nil }
+
+func (h *SyslogHandler) WriteLevel(level int, data []byte) (int, error) { return len(data), nil }
+
+func (h *SyslogHandler) Flush() {}
+
+func (h *SyslogHandler) Close() {}
@@ -0,0 +1,60 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenSyslog returns a unixgram socket standing in for a syslog daemon.
+func listenSyslog(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, path
+}
+
+func readSyslog(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestSyslogPriority(t *testing.T) {
+	conn, path := listenSyslog(t)
+	h := NewSyslogHandler("unixgram", path, "app")
+	l := NewWithHandler("app", h)
+	defer l.Close()
+	l.SetFlags(0)
+
+	for _, c := range []struct {
+		log  func(...interface{})
+		msg  string
+		want string
+	}{
+		// LOG_USER is 8, plus the severity
+		{l.Error, "boom", "<11>"},
+		{l.Warn, "disk", "<12>"},
+		{l.Info, "has ERROR: inside", "<14>"},
+	} {
+		c.log(c.msg)
+		got := readSyslog(t, conn)
+		if !strings.HasPrefix(got, c.want) || !strings.Contains(got, c.msg) {
+			t.Errorf("%s sent %q, want priority %s", c.msg, got, c.want)
+		}
+	}
+}
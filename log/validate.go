@@ -0,0 +1,80 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Validate checks that New(name, fp, mode) would be able to log, without
+// creating any file or goroutine: the mode must be one of the RotateMode
+// constants and fp must be, or be creatable as, a writable file.
+func Validate(name, fp string, mode int) error {
+	if name == "" {
+		return errors.New("validate: logger has no name")
+	}
+	if fp == "" {
+		return errors.New("validate: config must have filename")
+	}
+	if mode < RotateModeNoRotate || mode > RotateModeHour {
+		return fmt.Errorf("validate: unknown rotate mode %d", mode)
+	}
+	return newHandler(fp, mode).validate()
+}
+
+// validate checks the handler's limits and that its file can be written.
+func (w *RotateHandler) validate() error {
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"MaxSize", w.MaxSize},
+		{"MaxLines", w.MaxLines},
+		{"MaxDays", w.MaxDays},
+		{"MaxHours", w.MaxHours},
+		{"MaxBackups", w.MaxBackups},
+	} {
+		if limit.value < 0 {
+			return fmt.Errorf("validate: %s is negative: %d", limit.name, limit.value)
+		}
+	}
+	if w.Interval < 0 {
+		return fmt.Errorf("validate: Interval is negative: %s", w.Interval)
+	}
+	if w.MaxTotalSize < 0 {
+		return fmt.Errorf("validate: MaxTotalSize is negative: %d", w.MaxTotalSize)
+	}
+
+	fi, err := os.Stat(w.FilePath)
+	if err == nil {
+		if !fi.Mode().IsRegular() {
+			return fmt.Errorf("validate: %s is not a regular file", w.FilePath)
+		}
+		return writable(w.FilePath)
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("validate: %s", err)
+	}
+
+	// the file would be created, like createLogFile does, in the nearest
+	// existing parent directory
+	dir := filepath.Dir(w.FilePath)
+	for {
+		fi, err := os.Stat(dir)
+		if err == nil {
+			if !fi.IsDir() {
+				return fmt.Errorf("validate: %s is not a directory", dir)
+			}
+			return writable(dir)
+		}
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("validate: %s", err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("validate: no existing parent directory for %s", w.FilePath)
+		}
+		dir = parent
+	}
+}
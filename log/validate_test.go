@@ -0,0 +1,55 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "blocker")
+	if err := ioutil.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	readOnly := filepath.Join(dir, "ro")
+	if err := os.Mkdir(readOnly, 0555); err != nil {
+		t.Fatal(err)
+	}
+
+	type test struct {
+		name, fp string
+		mode     int
+		err      string
+	}
+	tests := []test{
+		{"app", "", RotateModeNoRotate, "filename"},
+		{"", filepath.Join(dir, "app.log"), RotateModeNoRotate, "no name"},
+		{"app", filepath.Join(dir, "app.log"), 42, "rotate mode"},
+		{"app", filepath.Join(dir, "app.log"), -1, "rotate mode"},
+		{"app", filepath.Join(blocker, "app.log"), RotateModeNoRotate, "not a directory"},
+		{"app", dir, RotateModeNoRotate, "not a regular file"},
+	}
+	if os.Getuid() != 0 { // root may write anywhere
+		tests = append(tests, test{"app", filepath.Join(readOnly, "app.log"), RotateModeNoRotate, "validate"})
+	}
+	for _, tt := range tests {
+		err := Validate(tt.name, tt.fp, tt.mode)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Validate(%q, %q, %d) = %v, want an error about %s", tt.name, tt.fp, tt.mode, err, tt.err)
+		}
+	}
+
+	fp := filepath.Join(dir, "new", "nested", "app.log")
+	if err := Validate("app", fp, RotateModeWeek); err != nil {
+		t.Errorf("Validate(%q) = %v", fp, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new")); !os.IsNotExist(err) {
+		t.Error("Validate created the directory")
+	}
+	if err := Validate("app", blocker, RotateMode16M); err != nil {
+		t.Errorf("Validate on an existing file = %v", err)
+	}
+}
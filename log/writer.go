@@ -0,0 +1,84 @@
+package log
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// Writer returns an io.Writer logging each write at LevelInfo, for libraries
+// that take an io.Writer or a *log.Logger. It replaces log.Logger's Writer,
+// which returned the raw handler.
+func (l *Vlogger) Writer() io.Writer {
+	return l.LevelWriter(LevelInfo)
+}
+
+// LevelWriter returns an io.Writer logging each write as one message at
+// level, without its trailing newline.
+func (l *Vlogger) LevelWriter(level int) io.Writer {
+	return &levelWriter{logger: l, level: level}
+}
+
+type levelWriter struct {
+	logger *Vlogger
+	level  int
+}
+
+func (w *levelWriter) Write(data []byte) (int, error) {
+	if w.logger.enabled(w.level) {
+		w.logger.output(w.level, strings.TrimSuffix(string(data), "\n"), nil)
+	}
+	return len(data), nil
+}
+
+// lockedWriter is a Vlogger's output. Both the embedded log.Logger and the
+// lines the Vlogger formats itself, such as JSON ones, go through it, so
+// they never interleave even on a writer that isn't safe for concurrent use.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(data)
+}
+
+// writeLevel writes a line at level, passing the level on if the writer is a
+// LevelHandler.
+func (w *lockedWriter) writeLevel(level int, data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return writeLevel(w.w, level, data)
+}
+
+// writeLevel writes data to w, with its level if w is a LevelHandler.
+func writeLevel(w io.Writer, level int, data []byte) (int, error) {
+	if lh, ok := w.(LevelHandler); ok {
+		return lh.WriteLevel(level, data)
+	}
+	return w.Write(data)
+}
+
+// SetOutput sets where l writes, keeping writes serialized. It replaces
+// log.Logger's SetOutput.
+func (l *Vlogger) SetOutput(w io.Writer) {
+	if l.out == nil {
+		l.Logger.SetOutput(w)
+		return
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w = w
+}
+
+// writeLine writes a line at level that l formatted itself.
+func (l *Vlogger) writeLine(level int, data []byte) {
+	if l.out == nil {
+		// a Vlogger built without a constructor
+		writeLevel(l.Logger.Writer(), level, data)
+		return
+	}
+	l.out.writeLevel(level, data)
+}
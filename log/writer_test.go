@@ -0,0 +1,48 @@
+package log
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestWriterThroughStdLogger(t *testing.T) {
+	h := newTestHandler(t, func(h *RotateHandler) {
+		h.MaxLines = 2
+		h.Rotatable = true
+	})
+	l := NewWithHandler("app", h)
+	l.SetFlags(0)
+	std := log.New(l.LevelWriter(LevelWarn), "http: ", 0)
+	for i := 1; i <= 3; i++ {
+		std.Printf("bad request %d", i)
+	}
+
+	got := archives(t, h)
+	if len(got) != 1 {
+		t.Fatalf("archives = %v, want one", got)
+	}
+	want := "app:WARN: http: bad request 1\napp:WARN: http: bad request 2\n"
+	if s := readFile(t, got[0]); s != want {
+		t.Errorf("archive = %q, want %q", s, want)
+	}
+	if s := readFile(t, h.FilePath); s != "app:WARN: http: bad request 3\n" {
+		t.Errorf("file = %q", s)
+	}
+}
+
+func TestWriterLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriter("app", &buf)
+	l.SetFlags(0)
+	l.SetLevel(LevelError)
+	w := l.Writer()
+	if n, err := w.Write([]byte("below the level\n")); n != 16 || err != nil {
+		t.Errorf("Write = %d, %v", n, err)
+	}
+	l.SetLevel(LevelInfo)
+	w.Write([]byte("one line\n"))
+	if want := "app:INFO: one line\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}